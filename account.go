@@ -0,0 +1,315 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"html"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/panshiqu/shopping/auth"
+	"github.com/panshiqu/shopping/config"
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/store"
+)
+
+// currentUser 当前登录用户，未登录时返回 ErrUnauthorized
+func currentUser(r *http.Request) (*define.User, *define.Session, error) {
+	s, err := auth.Lookup(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u, err := store.Ins.User(s.ID)
+	if err != nil {
+		if err == define.ErrNotExist {
+			err = define.ErrUnauthorized
+		}
+		return nil, nil, err
+	}
+
+	return u, s, nil
+}
+
+// requireLogin 未登录时跳转登录页，POST 请求同时校验 CSRF，返回 false 时已写入响应
+func requireLogin(w http.ResponseWriter, r *http.Request, name string) (*define.User, *define.Session, bool) {
+	u, s, err := currentUser(r)
+	if err == define.ErrUnauthorized {
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+		return nil, nil, false
+	}
+	if err != nil {
+		log.Println(name+" currentUser", err)
+		fmt.Fprint(w, err)
+		return nil, nil, false
+	}
+
+	if r.Method == http.MethodPost {
+		if err := auth.CheckCSRF(r, s); err != nil {
+			log.Println(name+" CheckCSRF", err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return nil, nil, false
+		}
+	}
+
+	return u, s, true
+}
+
+// viewAlias 首页、凑单等只读页面展示谁的订阅，u、s 为登录用户（未登录时为 nil）。
+// 未指定 alias 时展示全部商品，他人的订阅只能通过其分享令牌 share 查看
+func viewAlias(r *http.Request) (alias string, u *define.User, s *define.Session, err error) {
+	if u, s, err = currentUser(r); err != nil && err != define.ErrUnauthorized {
+		return "", nil, nil, err
+	}
+
+	if token := r.FormValue("share"); token != "" {
+		v, err := auth.Shared(token)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		return v.Alias, u, s, nil
+	}
+
+	alias = strings.ToLower(r.FormValue("alias"))
+	if alias == "" || (u != nil && alias == u.Alias) {
+		return alias, u, s, nil
+	}
+
+	return "", nil, nil, define.ErrUnauthorized
+}
+
+// apiUser 接口调用者：登录会话（非 GET 请求需带 X-CSRF-Token 头或 csrf 字段），或 Basic 认证的别名及密码
+func apiUser(r *http.Request) (*define.User, error) {
+	u, s, err := currentUser(r)
+	if err == nil {
+		if r.Method != http.MethodGet {
+			if err := auth.CheckCSRF(r, s); err != nil {
+				return nil, err
+			}
+		}
+		return u, nil
+	}
+	if err != define.ErrUnauthorized {
+		return nil, err
+	}
+
+	alias, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, define.ErrUnauthorized
+	}
+
+	return login(r, strings.ToLower(alias), password)
+}
+
+const (
+	loginFree    = 3                // 连续失败多少次后开始锁定
+	minLoginLock = time.Second      // 首次锁定时长，此后每次失败翻倍
+	maxLoginLock = 15 * time.Minute // 锁定时长上限，静默这么久后清零
+)
+
+type loginFailure struct {
+	count int
+	until time.Time
+}
+
+var loginMutex sync.Mutex
+
+var loginFailures = make(map[string]*loginFailure)
+
+// loginKeys 登录失败分别按别名及来源 IP 计数
+func loginKeys(r *http.Request, alias string) []string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return []string{"alias:" + alias, "ip:" + ip}
+}
+
+// loginLock 连续失败 count 次后的锁定时长
+func loginLock(count int) time.Duration {
+	if count < loginFree {
+		return 0
+	}
+	if n := count - loginFree; n < 20 && minLoginLock<<n < maxLoginLock {
+		return minLoginLock << n
+	}
+	return maxLoginLock
+}
+
+// login 校验别名及密码，别名或 IP 连续失败后指数退避，锁定期间直接拒绝，成功时清零
+func login(r *http.Request, alias, password string) (*define.User, error) {
+	keys := loginKeys(r, alias)
+
+	loginMutex.Lock()
+	for _, k := range keys {
+		if f, ok := loginFailures[k]; ok && time.Now().Before(f.until) {
+			loginMutex.Unlock()
+			return nil, define.ErrTooManyAttempts
+		}
+	}
+	loginMutex.Unlock()
+
+	u, err := auth.Authenticate(alias, password)
+	if err == define.ErrNotExist {
+		err = define.ErrUnauthorized
+	}
+
+	loginMutex.Lock()
+	defer loginMutex.Unlock()
+
+	if err == nil {
+		for _, k := range keys {
+			delete(loginFailures, k)
+		}
+		return u, nil
+	}
+
+	if err != define.ErrUnauthorized {
+		return nil, err
+	}
+
+	now := time.Now()
+	for k, f := range loginFailures {
+		if now.After(f.until.Add(maxLoginLock)) {
+			delete(loginFailures, k)
+		}
+	}
+
+	for _, k := range keys {
+		f, ok := loginFailures[k]
+		if !ok {
+			f = &loginFailure{}
+			loginFailures[k] = f
+		}
+
+		f.count++
+		f.until = now.Add(loginLock(f.count))
+	}
+
+	return nil, err
+}
+
+// adminPassword 校验管理密码，只接受 POST 表单字段 field 或 Basic 认证的密码，不接受 URL 参数
+func adminPassword(r *http.Request, field string) bool {
+	password := r.PostFormValue(field)
+	if password == "" {
+		_, password, _ = r.BasicAuth()
+	}
+	return password != "" && subtle.ConstantTimeCompare([]byte(password), []byte(config.Ins.AdminPassword)) == 1
+}
+
+// bindAdmin 管理员代为绑定：管理员会话并校验 CSRF，或 POST 表单的 admin_password 字段
+func bindAdmin(r *http.Request) bool {
+	if u, s, err := currentUser(r); err == nil && u.Admin && auth.CheckCSRF(r, s) == nil {
+		return true
+	}
+	return adminPassword(r, "admin_password")
+}
+
+// safeNext 登录后跳转的站内地址
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+func procLoginRequest(w http.ResponseWriter, r *http.Request) {
+	next := safeNext(r.FormValue("next"))
+
+	if r.Method != http.MethodPost {
+		fmt.Fprintf(w, `
+			<html>
+			<body>
+			<form method="post">
+			<input type="hidden" name="next" value="%s">
+			<input type="text" name="alias">*绑定时输入的别名<br />
+			<input type="password" name="password">*绑定时输入的密码<br /><br />
+			<input type="submit" value="登录"> 还没有账号？<a href='/bind' target='_blank'>绑定</a>
+			</form>
+			</body>
+			</html>
+			`, html.EscapeString(next))
+		return
+	}
+
+	alias := strings.ToLower(r.PostFormValue("alias"))
+
+	log.Println("procLoginRequest", alias)
+
+	u, err := login(r, alias, r.PostFormValue("password"))
+	if err != nil {
+		log.Println("procLoginRequest login", err)
+		fmt.Fprint(w, err)
+		return
+	}
+
+	if _, err := auth.Create(w, r, u.ID); err != nil {
+		log.Println("procLoginRequest Create", err)
+		fmt.Fprint(w, err)
+		return
+	}
+
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+func procLogoutRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		fmt.Fprint(w, define.ErrMethodNotAllowed)
+		return
+	}
+
+	if _, _, ok := requireLogin(w, r, "procLogoutRequest"); !ok {
+		return
+	}
+
+	if err := auth.Destroy(w, r); err != nil {
+		log.Println("procLogoutRequest Destroy", err)
+		fmt.Fprint(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func procShareRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		fmt.Fprint(w, define.ErrMethodNotAllowed)
+		return
+	}
+
+	u, _, ok := requireLogin(w, r, "procShareRequest")
+	if !ok {
+		return
+	}
+
+	share := r.PostFormValue("share") == "1"
+
+	log.Println("procShareRequest", u.Alias, share)
+
+	if !share {
+		if err := auth.Unshare(u.ID); err != nil {
+			log.Println("procShareRequest Unshare", err)
+			fmt.Fprint(w, err)
+			return
+		}
+
+		http.Redirect(w, r, "/?alias="+url.QueryEscape(u.Alias), http.StatusSeeOther)
+		return
+	}
+
+	token, err := auth.Share(u.ID)
+	if err != nil {
+		log.Println("procShareRequest Share", err)
+		fmt.Fprint(w, err)
+		return
+	}
+
+	link := config.Ins.PublicURL + "/?share=" + url.QueryEscape(token)
+	fmt.Fprintf(w, "<html><body>专属链接（他人只读，仅展示这一次，请妥善保存，重新生成或关闭分享后失效）：<a href='%[1]s' target='_blank'>%[1]s</a></body></html>", html.EscapeString(link))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/panshiqu/shopping/config"
+	"github.com/panshiqu/shopping/define"
+)
+
+func TestAdminPassword(t *testing.T) {
+	config.Ins = &config.Config{AdminPassword: "secret"}
+
+	post := func(form url.Values) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/admin", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+	basic := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	basic.SetBasicAuth("admin", "secret")
+
+	for _, v := range []struct {
+		name string
+		r    *http.Request
+		want bool
+	}{
+		{"表单", post(url.Values{"password": {"secret"}}), true},
+		{"表单密码错误", post(url.Values{"password": {"secre"}}), false},
+		{"Basic 认证", basic, true},
+		{"URL 参数", httptest.NewRequest(http.MethodGet, "/admin?password=secret", nil), false},
+		{"POST 请求的 URL 参数", httptest.NewRequest(http.MethodPost, "/admin?password=secret", nil), false},
+	} {
+		if got := adminPassword(v.r, "password"); got != v.want {
+			t.Errorf("%s: %v, want %v", v.name, got, v.want)
+		}
+	}
+}
+
+func TestCheckCaptcha(t *testing.T) {
+	captcha = map[string]int32{"a": 123456, "b": 654321}
+
+	for _, v := range []struct {
+		id, in string
+		want   bool
+	}{
+		{"a", "123456", true},
+		{"a", "123456", false}, // 已作废
+		{"b", "000000", false},
+		{"b", "654321", false}, // 校验失败同样作废
+		{"c", "0", false},
+	} {
+		if got := checkCaptcha(v.id, v.in); got != v.want {
+			t.Errorf("checkCaptcha(%s, %s) = %v, want %v", v.id, v.in, got, v.want)
+		}
+	}
+}
+
+func TestLoginLock(t *testing.T) {
+	for _, v := range []struct {
+		count int
+		want  time.Duration
+	}{
+		{1, 0},
+		{loginFree - 1, 0},
+		{loginFree, minLoginLock},
+		{loginFree + 1, 2 * minLoginLock},
+		{loginFree + 3, 8 * minLoginLock},
+		{loginFree + 30, maxLoginLock},
+	} {
+		if got := loginLock(v.count); got != v.want {
+			t.Errorf("loginLock(%d) = %v, want %v", v.count, got, v.want)
+		}
+	}
+
+	// 锁定期间不校验密码直接拒绝，别名或 IP 任一锁定均拒绝
+	defer func() { loginFailures = make(map[string]*loginFailure) }()
+	for _, k := range []string{"alias:alice", "ip:192.0.2.1"} {
+		loginFailures = map[string]*loginFailure{k: {count: loginFree, until: time.Now().Add(time.Minute)}}
+
+		r := httptest.NewRequest(http.MethodPost, "/login", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		alias := "alice"
+		if k == "ip:192.0.2.1" {
+			alias = "bob"
+		}
+		if _, err := login(r, alias, "secret"); err != define.ErrTooManyAttempts {
+			t.Errorf("%s: %v, want %v", k, err, define.ErrTooManyAttempts)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package alert
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/promotion"
+	"github.com/panshiqu/shopping/region"
+	"github.com/panshiqu/shopping/store"
+)
+
+// Check 校验订阅规则
+func Check(s *define.Subscription) error {
+	if s.PromAlert < 0 || s.PromAlert > define.PromAlertCoupon|define.PromAlertReduction|define.PromAlertGift|define.PromAlertOther {
+		return define.ErrIllegalRule
+	}
+	switch s.Rule {
+	case define.RuleMinPrice:
+		return nil
+	case define.RuleTargetPrice:
+		if s.Target > 0 {
+			return nil
+		}
+	case define.RulePercentDrop:
+		if s.Target > 0 && s.Target < 100 {
+			return nil
+		}
+	case define.RuleAverage:
+		if s.Days > 0 {
+			return nil
+		}
+	case define.RuleRestock, define.RuleDelist:
+		return nil
+	}
+	return define.ErrIllegalRule
+}
+
+// Average N日均价，不含下柜采样
+func Average(sku, days int64) (float64, error) {
+	return store.Ins.Average(sku, time.Now().Unix()-days*24*60*60)
+}
+
+// below 价格有效且低于阈值
+func below(price, threshold float64) bool {
+	return price > 0 && price <= threshold
+}
+
+// Evaluate 评估订阅规则，prev 为上次价格（为零表示首次采样，为负表示下柜），push 表示创历史新低或回到历史最低价
+func Evaluate(s *define.Subscription, page *define.Page, price, prev float64, push bool, avg float64) (string, bool) {
+	switch s.Rule {
+	case define.RuleMinPrice:
+		if push {
+			return fmt.Sprintf("%s降价至%.2f %s", page.Name, price, page.URL), true
+		}
+	case define.RuleTargetPrice:
+		if below(price, s.Target) && !below(prev, s.Target) {
+			return fmt.Sprintf("%s降价至%.2f，已低于目标价%.2f %s", page.Name, price, s.Target, page.URL), true
+		}
+	case define.RulePercentDrop:
+		threshold := s.BasePrice * (1 - s.Target/100)
+		if below(price, threshold) && !below(prev, threshold) {
+			return fmt.Sprintf("%s降价至%.2f，较订阅时%.2f降幅超过%g%% %s", page.Name, price, s.BasePrice, s.Target, page.URL), true
+		}
+	case define.RuleAverage:
+		if below(price, avg) && !below(prev, avg) {
+			return fmt.Sprintf("%s降价至%.2f，低于%d日均价%.2f %s", page.Name, price, s.Days, avg, page.URL), true
+		}
+	case define.RuleRestock:
+		if prev < 0 && price >= 0 {
+			return fmt.Sprintf("%s已到货，当前价格%.2f %s", page.Name, price, page.URL), true
+		}
+	case define.RuleDelist:
+		if prev > 0 && price < 0 {
+			return fmt.Sprintf("%s已下柜 %s", page.Name, page.URL), true
+		}
+	}
+	return "", false
+}
+
+// EvaluatePromotion 评估促销变化提醒，仅在出现所订阅类别的新促销时提醒，内容包含该类别的变化
+func EvaluatePromotion(s *define.Subscription, page *define.Page, price float64, added, removed []*define.Promotion) (string, bool) {
+	if s.PromAlert == 0 {
+		return "", false
+	}
+
+	var buf bytes.Buffer
+	var ok bool
+	for _, v := range added {
+		if s.PromAlert&define.PromAlertOf(v.Type) != 0 {
+			fmt.Fprintf(&buf, "\n+%s", v.Text)
+			ok = true
+		}
+	}
+	if !ok {
+		return "", false
+	}
+	for _, v := range removed {
+		if s.PromAlert&define.PromAlertOf(v.Type) != 0 {
+			fmt.Fprintf(&buf, "\n-%s", v.Text)
+		}
+	}
+
+	return fmt.Sprintf("%s促销变化，当前价格%.2f%s\n%s", page.Name, price, buf.String(), page.URL), true
+}
+
+// Subscriptions 商品的订阅，Area 为实际使用的配送区域
+func Subscriptions(sku int64) ([]*define.Subscription, error) {
+	subs, err := store.Ins.Subscribers(sku)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range subs {
+		v.Area = region.Effective(v.Area)
+	}
+
+	return subs, nil
+}
+
+// Match 逐个评估商品的订阅，返回需要提醒的用户及内容
+func Match(subs []*define.Subscription, page *define.Page, price float64, proms []*define.Promotion, c *define.Change) ([]*define.Alert, error) {
+	var err error
+	var added, removed []*define.Promotion
+	if c.PrevPrice != 0 {
+		added, removed = promotion.Diff(c.PrevPromotions, proms)
+	}
+
+	var out []*define.Alert
+	avgs := make(map[int64]float64)
+	for _, v := range subs {
+		var avg float64
+		if v.Rule == define.RuleAverage {
+			var ok bool
+			if avg, ok = avgs[v.Days]; !ok {
+				if avg, err = Average(page.SkuID, v.Days); err != nil {
+					return nil, err
+				}
+				avgs[v.Days] = avg
+			}
+		}
+
+		if msg, ok := Evaluate(v, page, price, c.PrevPrice, c.Push, avg); ok {
+			out = append(out, &define.Alert{
+				ID:      v.ID,
+				SkuID:   page.SkuID,
+				Message: msg,
+			})
+		}
+
+		if msg, ok := EvaluatePromotion(v, page, price, added, removed); ok {
+			out = append(out, &define.Alert{
+				ID:      v.ID,
+				SkuID:   page.SkuID,
+				Message: msg,
+			})
+		}
+	}
+
+	return out, nil
+}
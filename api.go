@@ -0,0 +1,426 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/panshiqu/shopping/cache"
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/health"
+	"github.com/panshiqu/shopping/history"
+	"github.com/panshiqu/shopping/notify"
+	"github.com/panshiqu/shopping/pricing"
+	"github.com/panshiqu/shopping/promotion"
+	"github.com/panshiqu/shopping/protect"
+	"github.com/panshiqu/shopping/region"
+	"github.com/panshiqu/shopping/spider"
+	"github.com/panshiqu/shopping/store"
+)
+
+func statusCode(err error) int {
+	switch err {
+	case define.ErrNotExist, sql.ErrNoRows:
+		return http.StatusNotFound
+	case define.ErrAlreadyExist:
+		return http.StatusConflict
+	case define.ErrUnauthorized:
+		return http.StatusUnauthorized
+	case define.ErrIllegalCSRF:
+		return http.StatusForbidden
+	case define.ErrMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case define.ErrTooManyAttempts:
+		return http.StatusTooManyRequests
+	case define.ErrCircuitOpen:
+		return http.StatusServiceUnavailable
+	case define.ErrToSmallPriority, define.ErrIllegalLen, define.ErrIllegalAlias, define.ErrIllegalPassword, define.ErrUnknownRetailer, define.ErrIllegalBucket, define.ErrIllegalRule, define.ErrUnknownChannel, define.ErrIllegalTarget, define.ErrIllegalPurchase, define.ErrEmptyCart, define.ErrUnknownMode, define.ErrIllegalArea:
+		return http.StatusBadRequest
+	}
+	switch err.(type) {
+	case *strconv.NumError, *time.ParseError:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("writeJSON Encode", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, statusCode(err), map[string]string{"error": err.Error()})
+}
+
+func procAPIIndexRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, define.ErrMethodNotAllowed)
+		return
+	}
+
+	alias, _, _, err := viewAlias(r)
+	if err != nil {
+		log.Println("procAPIIndexRequest viewAlias", err)
+		writeError(w, err)
+		return
+	}
+
+	data, err := selectIndex(alias)
+	if err != nil {
+		log.Println("procAPIIndexRequest selectIndex", err)
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, data)
+}
+
+func procAPISubscriptionsRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, define.ErrMethodNotAllowed)
+		return
+	}
+
+	alias, me, _, err := viewAlias(r)
+	if err != nil {
+		log.Println("procAPISubscriptionsRequest viewAlias", err)
+		writeError(w, err)
+		return
+	}
+
+	if alias == "" && me != nil {
+		alias = me.Alias
+	}
+
+	u, err := store.Ins.UserByAlias(alias)
+	if err != nil {
+		log.Println("procAPISubscriptionsRequest UserByAlias", err)
+		writeError(w, err)
+		return
+	}
+
+	out, err := store.Ins.SubscriptionsOf(u.ID)
+	if err != nil {
+		log.Println("procAPISubscriptionsRequest SubscriptionsOf", err)
+		writeError(w, err)
+		return
+	}
+
+	if out == nil {
+		out = []*define.Subscription{}
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func procAPIUsersRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, define.ErrMethodNotAllowed)
+		return
+	}
+
+	if !adminPassword(r, "password") {
+		writeError(w, define.ErrUnauthorized)
+		return
+	}
+
+	out, err := store.Ins.Users()
+	if err != nil {
+		log.Println("procAPIUsersRequest Users", err)
+		writeError(w, err)
+		return
+	}
+
+	if out == nil {
+		out = []*define.User{}
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func procAPIHistoryRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, define.ErrMethodNotAllowed)
+		return
+	}
+
+	sku, begin, end, bucket, err := parseHistoryArgs(r)
+	if err != nil {
+		log.Println("procAPIHistoryRequest parseHistoryArgs", err)
+		writeError(w, err)
+		return
+	}
+
+	h, err := history.Query(sku, begin, end, bucket)
+	if err != nil {
+		log.Println("procAPIHistoryRequest Query", err)
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h)
+}
+
+func procAPIChannelsRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, define.ErrMethodNotAllowed)
+		return
+	}
+
+	u, err := apiUser(r)
+	if err != nil {
+		log.Println("procAPIChannelsRequest apiUser", err)
+		writeError(w, err)
+		return
+	}
+
+	id := u.ID
+
+	channels, err := notify.Channels(id)
+	if err != nil {
+		log.Println("procAPIChannelsRequest Channels", err)
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, channels)
+}
+
+func procAPINotificationsRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, define.ErrMethodNotAllowed)
+		return
+	}
+
+	u, err := apiUser(r)
+	if err != nil {
+		log.Println("procAPINotificationsRequest apiUser", err)
+		writeError(w, err)
+		return
+	}
+
+	id := u.ID
+
+	limit := int64(100)
+	if v := r.FormValue("limit"); v != "" {
+		var err error
+		if limit, err = strconv.ParseInt(v, 10, 64); err != nil {
+			log.Println("procAPINotificationsRequest limit", err)
+			writeError(w, err)
+			return
+		}
+	}
+
+	ns, err := store.Ins.NotificationsOf(id, limit)
+	if err != nil {
+		log.Println("procAPINotificationsRequest Select", err)
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ns)
+}
+
+func procAPIPurchasesRequest(w http.ResponseWriter, r *http.Request) {
+	u, err := apiUser(r)
+	if err != nil {
+		log.Println("procAPIPurchasesRequest apiUser", err)
+		writeError(w, err)
+		return
+	}
+
+	id := u.ID
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		p, err := parsePurchase(r)
+		if err != nil {
+			log.Println("procAPIPurchasesRequest parsePurchase", err)
+			writeError(w, err)
+			return
+		}
+
+		p.UserID = id
+
+		if err := protect.Add(p); err != nil {
+			log.Println("procAPIPurchasesRequest Add", err)
+			writeError(w, err)
+			return
+		}
+	default:
+		writeError(w, define.ErrMethodNotAllowed)
+		return
+	}
+
+	ps, err := store.Ins.PurchasesOf(id)
+	if err != nil {
+		log.Println("procAPIPurchasesRequest Select", err)
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ps)
+}
+
+func procAPIPromotionsRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, define.ErrMethodNotAllowed)
+		return
+	}
+
+	alias, _, _, err := viewAlias(r)
+	if err != nil {
+		log.Println("procAPIPromotionsRequest viewAlias", err)
+		writeError(w, err)
+		return
+	}
+
+	data, err := selectIndex(alias)
+	if err != nil {
+		log.Println("procAPIPromotionsRequest selectIndex", err)
+		writeError(w, err)
+		return
+	}
+
+	out := make(map[int64][]*define.Promotion)
+	for _, v := range data.Args {
+		if proms := promotion.Filter(v.Promotions, r.FormValue("type"), r.FormValue("q")); len(proms) != 0 {
+			out[v.SkuID] = proms
+		}
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func procAPIQuoteRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, define.ErrMethodNotAllowed)
+		return
+	}
+
+	sku, err := strconv.ParseInt(r.FormValue("sku"), 10, 64)
+	if err != nil {
+		log.Println("procAPIQuoteRequest ParseInt", err)
+		writeError(w, err)
+		return
+	}
+
+	args := cache.Select([]int64{sku})
+	if len(args) == 0 || args[0].Breakdown == nil {
+		writeError(w, define.ErrNotExist)
+		return
+	}
+
+	mode := r.FormValue("mode")
+	if mode == "" {
+		mode = pricing.Default
+	}
+
+	b, err := pricing.Compute(mode, args[0].Promotions, args[0].Breakdown.ListPrice)
+	if err != nil {
+		log.Println("procAPIQuoteRequest Compute", err)
+		writeError(w, err)
+		return
+	}
+
+	b.Tax = args[0].Breakdown.Tax
+	b.Round()
+
+	writeJSON(w, http.StatusOK, b)
+}
+
+func procAPIMatrixRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, define.ErrMethodNotAllowed)
+		return
+	}
+
+	sku, err := strconv.ParseInt(r.FormValue("sku"), 10, 64)
+	if err != nil {
+		log.Println("procAPIMatrixRequest ParseInt", err)
+		writeError(w, err)
+		return
+	}
+
+	args := cache.Select([]int64{sku})
+	if len(args) == 0 {
+		writeError(w, define.ErrNotExist)
+		return
+	}
+
+	rps, err := store.Ins.RegionPrices(sku)
+	if err != nil {
+		log.Println("procAPIMatrixRequest Select", err)
+		writeError(w, err)
+		return
+	}
+
+	out := []*define.RegionPrice{{
+		SkuID:      sku,
+		Area:       region.Default,
+		Price:      args[0].Price,
+		MinPrice:   args[0].MinPrice,
+		Stock:      args[0].Stock,
+		Breakdown:  args[0].Breakdown,
+		Promotions: args[0].Promotions,
+	}}
+	for _, v := range rps {
+		if v.Area != region.Default {
+			out = append(out, v)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func procAPICartRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, define.ErrMethodNotAllowed)
+		return
+	}
+
+	_, _, plan, err := parseCart(r)
+	if err != nil {
+		log.Println("procAPICartRequest parseCart", err)
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, plan)
+}
+
+func procAPIHealthRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, define.ErrMethodNotAllowed)
+		return
+	}
+
+	if !adminPassword(r, "password") {
+		writeError(w, define.ErrUnauthorized)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, health.Stats())
+}
+
+func procAPISpiderRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, define.ErrMethodNotAllowed)
+		return
+	}
+
+	if !adminPassword(r, "password") {
+		writeError(w, define.ErrUnauthorized)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, spider.Stats())
+}
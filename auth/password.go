@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPassword 密码最短长度
+const MinPassword = 6
+
+// MaxPassword 密码最长长度，bcrypt 只使用前 72 字节
+const MaxPassword = 72
+
+// Hash 密码摘要
+func Hash(password string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Verify 校验密码，rehash 表示保存的是早期的明文密码，校验通过后应改存摘要
+func Verify(hash, password string) (ok, rehash bool) {
+	if !strings.HasPrefix(hash, "$2") {
+		return hash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(password)) == 1, true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil, false
+}
+
+// Bind 绑定别名、密码及配送区域，密码保存摘要，别名已被他人使用时返回 ErrAlreadyExist
+func Bind(in *define.User) error {
+	hash, err := Hash(in.Password)
+	if err != nil {
+		return err
+	}
+	u := *in
+	u.Password = hash
+	return store.Ins.Bind(&u)
+}
+
+// Authenticate 校验别名及密码，不匹配时返回 ErrNotExist，早期的明文密码校验通过后改存摘要
+func Authenticate(alias, password string) (*define.User, error) {
+	u, err := store.Ins.UserByAlias(alias)
+	if err != nil {
+		return nil, err
+	}
+	ok, rehash := Verify(u.Password, password)
+	if !ok {
+		return nil, define.ErrNotExist
+	}
+	if rehash {
+		if u.Password, err = Hash(password); err != nil {
+			return nil, err
+		}
+		if err := store.Ins.SetPassword(u.ID, u.Password); err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
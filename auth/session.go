@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/store"
+)
+
+// CookieName 会话 Cookie 名称
+const CookieName = "session"
+
+// TTL 会话有效期
+var TTL = 30 * 24 * time.Hour
+
+func random() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// digest 库中只保存会话令牌的摘要
+func digest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create 为用户创建会话并写入 HTTP-only Cookie
+func Create(w http.ResponseWriter, r *http.Request, id string) (*define.Session, error) {
+	now := time.Now()
+
+	s := &define.Session{ID: id, Expire: now.Add(TTL).Unix()}
+
+	var err error
+	if s.Token, err = random(); err != nil {
+		return nil, err
+	}
+	if s.CSRF, err = random(); err != nil {
+		return nil, err
+	}
+
+	if err := store.Ins.AddSession(&define.Session{Token: digest(s.Token), ID: s.ID, CSRF: s.CSRF, Expire: s.Expire}, now.Unix()); err != nil {
+		return nil, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    s.Token,
+		Path:     "/",
+		Expires:  time.Unix(s.Expire, 0),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return s, nil
+}
+
+// Lookup 请求携带的有效会话，没有时返回 ErrUnauthorized
+func Lookup(r *http.Request) (*define.Session, error) {
+	c, err := r.Cookie(CookieName)
+	if err != nil || c.Value == "" {
+		return nil, define.ErrUnauthorized
+	}
+
+	s, err := store.Ins.Session(digest(c.Value), time.Now().Unix())
+	if err != nil {
+		if err == define.ErrNotExist {
+			return nil, define.ErrUnauthorized
+		}
+		return nil, err
+	}
+
+	s.Token = c.Value
+	return s, nil
+}
+
+// Destroy 注销请求携带的会话并清除 Cookie
+func Destroy(w http.ResponseWriter, r *http.Request) error {
+	http.SetCookie(w, &http.Cookie{Name: CookieName, Path: "/", MaxAge: -1, HttpOnly: true, Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode})
+
+	c, err := r.Cookie(CookieName)
+	if err != nil {
+		return nil
+	}
+
+	return store.Ins.DeleteSession(digest(c.Value))
+}
+
+// Revoke 注销用户的全部会话，用于重新绑定修改密码后
+func Revoke(id string) error {
+	return store.Ins.DeleteSessions(id)
+}
+
+// CheckCSRF 校验表单 csrf 字段或 X-CSRF-Token 头
+func CheckCSRF(r *http.Request, s *define.Session) error {
+	v := r.PostFormValue("csrf")
+	if v == "" {
+		v = r.Header.Get("X-CSRF-Token")
+	}
+	if v == "" || subtle.ConstantTimeCompare([]byte(v), []byte(s.CSRF)) != 1 {
+		return define.ErrIllegalCSRF
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/store"
+)
+
+// Share 开启分享并生成新的分享令牌，旧令牌随即失效，库中只保存摘要，令牌仅在生成时展示
+func Share(id string) (string, error) {
+	token, err := random()
+	if err != nil {
+		return "", err
+	}
+
+	if err := store.Ins.SetShare(id, digest(token)); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Unshare 关闭分享，已发出的分享令牌随即失效
+func Unshare(id string) error {
+	return store.Ins.SetShare(id, "")
+}
+
+// Shared 分享令牌对应的用户，无效时返回 ErrUnauthorized
+func Shared(token string) (*define.User, error) {
+	if token == "" {
+		return nil, define.ErrUnauthorized
+	}
+
+	u, err := store.Ins.UserByShare(digest(token))
+	if err == define.ErrNotExist {
+		return nil, define.ErrUnauthorized
+	}
+	return u, err
+}
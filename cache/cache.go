@@ -1,12 +1,13 @@
 package cache
 
 import (
-	"database/sql"
+	"encoding/json"
 	"sync"
 	"time"
 
-	"github.com/panshiqu/shopping/db"
 	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/promotion"
+	"github.com/panshiqu/shopping/store"
 )
 
 var (
@@ -18,66 +19,101 @@ func init() {
 	data = make(map[int64]*define.IndexArgs)
 }
 
-// Update 更新
-func Update(id int64, price float64, content string, name string) (bool, error) {
+// Update 更新，返回相较上次采样的变化
+func Update(retailer string, page *define.Page, price float64, proms []*define.Promotion, b *define.Breakdown) (*define.Change, error) {
 	mtx.Lock()
 	defer mtx.Unlock()
 
-	args, ok := data[id]
+	args, ok := data[page.SkuID]
 	if !ok {
 		args = &define.IndexArgs{
-			SkuID: id,
-			Name:  name,
+			SkuID:    page.SkuID,
+			Retailer: retailer,
+			Stock:    define.StockInStock,
 		}
 
-		if err := db.Ins.QueryRow("SELECT price,content FROM jd WHERE sku = ? ORDER BY id DESC LIMIT 1", args.SkuID).Scan(&args.Price, &args.Content); err != nil && err != sql.ErrNoRows {
-			return false, err
+		last, err := store.Ins.LastSample(args.SkuID)
+		if err != nil {
+			return nil, err
 		}
 
-		if err := db.Ins.QueryRow("SELECT min_price,max_price,UNIX_TIMESTAMP(insert_timestamp) FROM sku WHERE sku = ?", args.SkuID).Scan(&args.MinPrice, &args.MaxPrice, &args.InsertTimestamp); err != nil {
-			return false, err
+		if last != nil {
+			args.Price, args.KoBeginTime, args.KoEndTime, args.Promotions = last.Price, last.KoBeginTime, last.KoEndTime, last.Promotions
+
+			if len(last.Breakdown) != 0 {
+				args.Breakdown = &define.Breakdown{}
+				if err := json.Unmarshal(last.Breakdown, args.Breakdown); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		sku, err := store.Ins.SKU(args.SkuID)
+		if err != nil {
+			return nil, err
 		}
 
-		if err := db.Ins.QueryRow("SELECT COUNT(*) FROM jd WHERE sku = ?", args.SkuID).Scan(&args.Sampling); err != nil {
-			return false, err
+		args.MinPrice, args.MaxPrice, args.Stock, args.InsertTimestamp = sku.MinPrice, sku.MaxPrice, sku.Stock, sku.InsertTimestamp
+
+		if args.Sampling, err = store.Ins.CountSamples(args.SkuID); err != nil {
+			return nil, err
 		}
 
 		data[args.SkuID] = args
 	}
 
+	args.Name = page.Name
+	args.URL = page.URL
+	args.Src = page.Src
+
 	args.Timestamp = time.Now().Format("01-02 15:04:05")
 
-	if price == args.Price && content == args.Content {
-		return false, define.ErrDataSame
+	if price == args.Price && page.KoBeginTime == args.KoBeginTime && page.KoEndTime == args.KoEndTime && promotion.Equal(proms, args.Promotions) {
+		return nil, define.ErrDataSame
 	}
 
-	var push bool
+	var push, changed bool
+
+	stock := define.StockOf(price)
 
 	if price == args.MinPrice && args.Price != args.MinPrice {
 		push = true
 	}
 
-	if (price != -0.99) && (price < args.MinPrice || args.MinPrice == 0) {
-		push = true
+	if stock == define.StockInStock && (price < args.MinPrice || args.MinPrice == 0) {
+		push, changed = true, true
 		args.MinPrice = price
-
-		if _, err := db.Ins.Exec("UPDATE sku SET min_price = ? WHERE sku = ?", args.MinPrice, args.SkuID); err != nil {
-			return false, err
-		}
 	}
 
-	if price > args.MaxPrice || args.MaxPrice == 0 {
+	if stock == define.StockInStock && (price > args.MaxPrice || args.MaxPrice == 0) {
+		changed = true
 		args.MaxPrice = price
+	}
+
+	if stock != args.Stock {
+		changed = true
+		args.Stock = stock
+	}
 
-		if _, err := db.Ins.Exec("UPDATE sku SET max_price = ? WHERE sku = ?", args.MaxPrice, args.SkuID); err != nil {
-			return false, err
+	if changed {
+		if err := store.Ins.UpdateSKU(args.SkuID, args.MinPrice, args.MaxPrice, args.Stock); err != nil {
+			return nil, err
 		}
 	}
 
+	c := &define.Change{
+		Push:           push,
+		PrevPrice:      args.Price,
+		PrevPromotions: args.Promotions,
+	}
+
 	args.Price = price
-	args.Content = content
+	args.Promotions = proms
+	args.Breakdown = b
+	args.KoBeginTime = page.KoBeginTime
+	args.KoEndTime = page.KoEndTime
 	args.Sampling++
-	return push, nil
+	return c, nil
 }
 
 // Select 查询
@@ -101,8 +137,6 @@ func Exist(in int64) bool {
 	if _, ok := data[in]; ok {
 		return true
 	}
-	if err := db.Ins.QueryRow("SELECT sku FROM sku WHERE sku = ?", in).Scan(&in); err != sql.ErrNoRows {
-		return true
-	}
-	return false
+	_, err := store.Ins.SKU(in)
+	return err != define.ErrNotExist
 }
@@ -0,0 +1,244 @@
+package cart
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/pricing"
+)
+
+// MaxCombinations 穷举分组的组合数上限，超过后按共享度贪心分组再逐项调整
+var MaxCombinations = 1 << 16
+
+// MaxLines 凑单的商品数上限
+var MaxLines = 50
+
+type item struct {
+	line    *define.CartLine
+	options []*define.Promotion // 首项为 nil 表示不参与促销
+}
+
+type group struct {
+	promotion *define.Promotion
+	lines     []*define.CartLine
+}
+
+// Plan 按共享的满减、N元选M件、多买优惠为商品分组使总价最低，优惠券不参与凑单。
+// 数量由调用方校验，不超过 pricing.MaxQuantity
+func Plan(args []*define.IndexArgs, quantity map[int64]int64) *define.CartPlan {
+	plan := &define.CartPlan{}
+
+	var items []*item
+	for _, v := range args {
+		q := quantity[v.SkuID]
+		if q <= 0 {
+			continue
+		}
+		price := v.Price
+		if v.Breakdown != nil {
+			price = v.Breakdown.ListPrice
+		}
+		if v.IsOffShelf() || price <= 0 {
+			plan.Unavailable = append(plan.Unavailable, v.SkuID)
+			continue
+		}
+		it := &item{
+			line:    &define.CartLine{SkuID: v.SkuID, Name: v.Name, Price: price, Quantity: q},
+			options: []*define.Promotion{nil},
+		}
+		for _, vv := range v.Promotions {
+			switch vv.Type {
+			case define.PromFullReduction, define.PromPickN, define.PromMultiBuy:
+				it.options = append(it.options, vv)
+			}
+		}
+		items = append(items, it)
+		plan.ListTotal += price * float64(q)
+	}
+
+	best := make([]int, len(items))
+	if combinations(items) <= MaxCombinations {
+		bestTotal := total(items, best)
+		search(items, make([]int, len(items)), 0, best, &bestTotal)
+	} else {
+		greedy(items, best)
+		improve(items, best)
+	}
+
+	for _, g := range build(items, best) {
+		cg := &define.CartGroup{}
+		if g.promotion != nil {
+			cg.Promotion, cg.Pid = g.promotion.Text, g.promotion.Pid
+		}
+		cg.Lines = g.lines
+		cg.Subtotal, cg.Reduction = cost(g)
+		cg.Total = round(cg.Subtotal - cg.Reduction)
+		cg.Subtotal, cg.Reduction = round(cg.Subtotal), round(cg.Reduction)
+		plan.Groups = append(plan.Groups, cg)
+		plan.Total += cg.Total
+	}
+
+	plan.ListTotal = round(plan.ListTotal)
+	plan.Total = round(plan.Total)
+	plan.Saving = round(plan.ListTotal - plan.Total)
+	return plan
+}
+
+// key 促销分组键，无促销编号时只在同一商品内合并
+func key(sku int64, in *define.Promotion) string {
+	if in.Pid != "" {
+		return in.Pid
+	}
+	return fmt.Sprintf("%d_%s%s", sku, in.Type, in.Text)
+}
+
+func combinations(items []*item) int {
+	n := 1
+	for _, v := range items {
+		if n *= len(v.options); n > MaxCombinations {
+			break
+		}
+	}
+	return n
+}
+
+// build 按选择结果分组，参与促销的分组在前，不参与促销的商品归入最后一组
+func build(items []*item, choice []int) []*group {
+	var out []*group
+	none := &group{}
+	index := make(map[string]*group)
+	for k, v := range items {
+		p := v.options[choice[k]]
+		if p == nil {
+			none.lines = append(none.lines, v.line)
+			continue
+		}
+		g, ok := index[key(v.line.SkuID, p)]
+		if !ok {
+			g = &group{promotion: p}
+			index[key(v.line.SkuID, p)] = g
+			out = append(out, g)
+		}
+		g.lines = append(g.lines, v.line)
+	}
+	if len(none.lines) != 0 {
+		out = append(out, none)
+	}
+	return out
+}
+
+// cost 分组小计及优惠金额
+func cost(g *group) (subtotal, reduction float64) {
+	var q int64
+	for _, v := range g.lines {
+		subtotal += v.Price * float64(v.Quantity)
+		q += v.Quantity
+	}
+	p := g.promotion
+	if p == nil {
+		return
+	}
+	switch p.Type {
+	case define.PromFullReduction:
+		reduction = pricing.Reduction(p, subtotal)
+	case define.PromPickN:
+		if p.Count <= 0 {
+			return
+		}
+		// 优先用高价商品凑N元选M件，按件数逐行取用
+		lines := append([]*define.CartLine(nil), g.lines...)
+		sort.SliceStable(lines, func(i, j int) bool { return lines[i].Price > lines[j].Price })
+		var sum float64
+		var n int
+		left := lines[0].Quantity
+		for k := int64(1); k <= q/p.Count; k++ {
+			for need := p.Count; need > 0; {
+				for left == 0 {
+					n++
+					left = lines[n].Quantity
+				}
+				take := need
+				if take > left {
+					take = left
+				}
+				sum += lines[n].Price * float64(take)
+				need, left = need-take, left-take
+			}
+			if v := sum - float64(k)*p.Threshold; v > reduction {
+				reduction = v
+			}
+		}
+	case define.PromMultiBuy:
+		if p.Rate > 0 && p.Count > 0 && q >= p.Count {
+			reduction = subtotal * (1 - p.Rate)
+		}
+	}
+	return
+}
+
+func total(items []*item, choice []int) (out float64) {
+	for _, g := range build(items, choice) {
+		subtotal, reduction := cost(g)
+		out += subtotal - reduction
+	}
+	return
+}
+
+// search 穷举每件商品参与的促销
+func search(items []*item, choice []int, n int, best []int, bestTotal *float64) {
+	if n == len(items) {
+		if t := total(items, choice); t < *bestTotal-1e-9 {
+			*bestTotal = t
+			copy(best, choice)
+		}
+		return
+	}
+	for k := range items[n].options {
+		choice[n] = k
+		search(items, choice, n+1, best, bestTotal)
+	}
+}
+
+// greedy 每件商品选择被最多商品共享的促销
+func greedy(items []*item, choice []int) {
+	shared := make(map[string]int)
+	for _, v := range items {
+		for _, vv := range v.options[1:] {
+			shared[key(v.line.SkuID, vv)]++
+		}
+	}
+	for k, v := range items {
+		for kk, vv := range v.options[1:] {
+			if choice[k] == 0 || shared[key(v.line.SkuID, vv)] > shared[key(v.line.SkuID, v.options[choice[k]])] {
+				choice[k] = kk + 1
+			}
+		}
+	}
+}
+
+// improve 逐项调整商品参与的促销直至总价不再降低
+func improve(items []*item, choice []int) {
+	best := total(items, choice)
+	for changed := true; changed; {
+		changed = false
+		for k, v := range items {
+			prev := choice[k]
+			for kk := range v.options {
+				if kk == prev {
+					continue
+				}
+				choice[k] = kk
+				if t := total(items, choice); t < best-1e-9 {
+					best, prev, changed = t, kk, true
+				}
+			}
+			choice[k] = prev
+		}
+	}
+}
+
+func round(in float64) float64 {
+	return math.Round(in*100) / 100
+}
@@ -0,0 +1,47 @@
+package cart
+
+import (
+	"testing"
+
+	"github.com/panshiqu/shopping/define"
+)
+
+func arg(sku int64, price float64, proms ...*define.Promotion) *define.IndexArgs {
+	return &define.IndexArgs{SkuID: sku, Price: price, Stock: define.StockInStock, Promotions: proms}
+}
+
+func TestPlan(t *testing.T) {
+	full := &define.Promotion{Type: define.PromFullReduction, Pid: "1", Text: "满100减20", Threshold: 100, Discount: 20}
+	pick := &define.Promotion{Type: define.PromPickN, Pid: "2", Text: "99元选3件", Threshold: 99, Count: 3}
+	multi := &define.Promotion{Type: define.PromMultiBuy, Pid: "3", Text: "2件9折", Count: 2, Rate: 0.9}
+	same := &define.Promotion{Type: define.PromFullReduction, Text: "满100减20", Threshold: 100, Discount: 20}
+
+	for _, v := range []struct {
+		name     string
+		args     []*define.IndexArgs
+		quantity map[int64]int64
+		total    float64
+		groups   int
+	}{
+		{"无促销", []*define.IndexArgs{arg(1, 30)}, map[int64]int64{1: 2}, 60, 1},
+		{"共享满减", []*define.IndexArgs{arg(1, 60, full), arg(2, 50, full)}, map[int64]int64{1: 1, 2: 1}, 90, 1},
+		{"N元选M件按件数", []*define.IndexArgs{arg(1, 50, pick), arg(2, 40, pick)}, map[int64]int64{1: 2, 2: 1}, 99, 1},
+		{"N元选M件不足件数", []*define.IndexArgs{arg(1, 50, pick)}, map[int64]int64{1: 2}, 100, 1},
+		{"多买优惠", []*define.IndexArgs{arg(1, 100, multi)}, map[int64]int64{1: 2}, 180, 1},
+		{"无促销编号不跨商品合并", []*define.IndexArgs{arg(1, 60, same), arg(2, 50, same)}, map[int64]int64{1: 1, 2: 1}, 110, 1},
+		{"下柜", []*define.IndexArgs{{SkuID: 1, Price: -1}, arg(2, 10)}, map[int64]int64{1: 1, 2: 1}, 10, 1},
+	} {
+		p := Plan(v.args, v.quantity)
+		if p.Total != v.total || len(p.Groups) != v.groups {
+			t.Errorf("%s: total %v groups %d, want %v %d", v.name, p.Total, len(p.Groups), v.total, v.groups)
+		}
+	}
+}
+
+func TestCostLargeQuantity(t *testing.T) {
+	pick := &define.Promotion{Type: define.PromPickN, Text: "99元选3件", Threshold: 99, Count: 3}
+	g := &group{promotion: pick, lines: []*define.CartLine{{SkuID: 1, Price: 50, Quantity: 1e6}}}
+	if _, reduction := cost(g); reduction <= 0 {
+		t.Fatal(reduction)
+	}
+}
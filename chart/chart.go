@@ -0,0 +1,99 @@
+package chart
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/panshiqu/shopping/define"
+)
+
+const (
+	width   = 600
+	height  = 200
+	padding = 40
+)
+
+// SVG 绘制价格折线图，标记最低价、最高价并以阴影标记秒杀及下柜时间段
+func SVG(w io.Writer, h *define.History, windows []*define.Window) error {
+	bw := bufio.NewWriter(w)
+
+	var samples []*define.Sample
+	for _, v := range h.Samples {
+		if v.Stock == define.StockInStock {
+			samples = append(samples, v)
+		}
+	}
+
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-size="10">`, width, height, width, height)
+	fmt.Fprintf(bw, `<rect width="%d" height="%d" fill="white" stroke="#ccc" />`, width, height)
+
+	if len(samples) == 0 {
+		fmt.Fprintf(bw, `<text x="%d" y="%d" text-anchor="middle">暂无数据</text></svg>`, width/2, height/2)
+		return bw.Flush()
+	}
+
+	begin, end := h.Samples[0].Timestamp, time.Now().Unix()
+	if h.End < end {
+		end = h.End
+	}
+	if end <= begin {
+		end = begin + 1
+	}
+	low, high := h.Min, h.Max
+	if high <= low {
+		low, high = low-1, high+1
+	}
+
+	x := func(ts int64) float64 {
+		if ts < begin {
+			ts = begin
+		}
+		if ts > end {
+			ts = end
+		}
+		return padding + float64(ts-begin)/float64(end-begin)*(width-2*padding)
+	}
+	y := func(price float64) float64 {
+		return height - padding/2 - (price-low)/(high-low)*(height-padding)
+	}
+
+	for _, v := range windows {
+		if v.End < begin || v.Begin > end {
+			continue
+		}
+		fmt.Fprintf(bw, `<rect x="%.1f" y="0" width="%.1f" height="%d" fill="red" fill-opacity="0.15"><title>京东秒杀 %s - %s</title></rect>`,
+			x(v.Begin), x(v.End)-x(v.Begin), height, time.Unix(v.Begin, 0).Format("01-02 15:04"), time.Unix(v.End, 0).Format("01-02 15:04"))
+	}
+
+	for k, v := range h.Samples {
+		if v.Stock != define.StockOffShelf {
+			continue
+		}
+		next := end
+		if k+1 < len(h.Samples) {
+			next = h.Samples[k+1].Timestamp
+		}
+		fmt.Fprintf(bw, `<rect x="%.1f" y="0" width="%.1f" height="%d" fill="gray" fill-opacity="0.3"><title>下柜</title></rect>`, x(v.Timestamp), x(next)-x(v.Timestamp), height)
+	}
+
+	fmt.Fprintf(bw, `<polyline fill="none" stroke="steelblue" stroke-width="1.5" points="`)
+	for k, v := range samples {
+		if k != 0 {
+			fmt.Fprintf(bw, "%.1f,%.1f ", x(v.Timestamp), y(samples[k-1].Price))
+		}
+		fmt.Fprintf(bw, "%.1f,%.1f ", x(v.Timestamp), y(v.Price))
+	}
+	fmt.Fprintf(bw, `%.1f,%.1f" />`, x(end), y(samples[len(samples)-1].Price))
+
+	fmt.Fprintf(bw, `<circle cx="%.1f" cy="%.1f" r="3" fill="green" /><text x="%.1f" y="%.1f" fill="green">最低 %.2f</text>`,
+		x(h.MinTimestamp), y(h.Min), x(h.MinTimestamp)+4, y(h.Min)-4, h.Min)
+	fmt.Fprintf(bw, `<circle cx="%.1f" cy="%.1f" r="3" fill="red" /><text x="%.1f" y="%.1f" fill="red">最高 %.2f</text>`,
+		x(h.MaxTimestamp), y(h.Max), x(h.MaxTimestamp)+4, y(h.Max)+12, h.Max)
+
+	fmt.Fprintf(bw, `<text x="%d" y="%d">%s</text><text x="%d" y="%d" text-anchor="end">%s</text></svg>`,
+		padding, height-4, time.Unix(begin, 0).Format("2006-01-02"), width-padding, height-4, time.Unix(end, 0).Format("2006-01-02"))
+
+	return bw.Flush()
+}
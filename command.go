@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/panshiqu/shopping/config"
+	"github.com/panshiqu/shopping/fakejd"
+	"github.com/panshiqu/shopping/fixture"
+	"github.com/panshiqu/shopping/migrate"
+	"github.com/panshiqu/shopping/notify"
+	"github.com/panshiqu/shopping/pricing"
+	"github.com/panshiqu/shopping/region"
+	"github.com/panshiqu/shopping/spider"
+	"github.com/panshiqu/shopping/store"
+)
+
+// commands 子命令，如 shopping reprocess -dry-run
+var commands = map[string]func(args []string) error{
+	"reprocess": cmdReprocess,
+	"record":    cmdRecord,
+	"replay":    cmdReplay,
+	"fakejd":    cmdFakeJD,
+	"migrate":   cmdMigrate,
+}
+
+// loadConfig 加载配置并应用到各包
+func loadConfig(fs *flag.FlagSet) error {
+	if err := config.Load(fs); err != nil {
+		return err
+	}
+	c := config.Ins
+	region.Default = c.Area
+	pricing.Default = c.PricingMode
+	spider.Workers, spider.MultiArea, spider.MaxAreas = c.Workers, c.MultiArea, c.MaxAreas
+	notify.Register(&notify.WeChat{URL: c.PushURL})
+	return nil
+}
+
+// openStore 打开存储并执行未执行的迁移
+func openStore() error {
+	if err := store.Open(config.Ins.DataSource); err != nil {
+		return err
+	}
+	n, err := migrate.Up()
+	if n != 0 {
+		log.Println("openStore migrate", n)
+	}
+	return err
+}
+
+// cmdMigrate 查看迁移状态、执行或回滚迁移
+func cmdMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	down := fs.Int("down", 0, "回滚的迁移个数")
+	status := fs.Bool("status", false, "只输出迁移状态")
+	config.Bind(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := loadConfig(fs); err != nil {
+		return err
+	}
+	if err := store.Open(config.Ins.DataSource); err != nil {
+		return err
+	}
+	switch {
+	case *status:
+	case *down > 0:
+		n, err := migrate.Down(*down)
+		fmt.Println("down", n)
+		if err != nil {
+			return err
+		}
+	default:
+		n, err := migrate.Up()
+		fmt.Println("up", n)
+		if err != nil {
+			return err
+		}
+	}
+	return migrate.Status(os.Stdout)
+}
+
+func cmdReprocess(args []string) error {
+	fs := flag.NewFlagSet("reprocess", flag.ExitOnError)
+	sku := fs.Int64("sku", 0, "商品编号，为零时处理全部商品")
+	dryRun := fs.Bool("dry-run", false, "只输出差异不写库")
+	config.Bind(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := loadConfig(fs); err != nil {
+		return err
+	}
+	if err := openStore(); err != nil {
+		return err
+	}
+	return spider.Reprocess(os.Stdout, *sku, *dryRun)
+}
+
+func probe(retailer string, sku int64) error {
+	page, proms, b, err := spider.Probe(context.Background(), retailer, sku)
+	if err != nil {
+		return err
+	}
+	fmt.Println(page.SkuID, page.Name)
+	for _, v := range proms {
+		fmt.Println("\t", v.Text)
+	}
+	fmt.Println(b)
+	return nil
+}
+
+// cmdRecord 抓取并把响应录制到目录
+func cmdRecord(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	dir := fs.String("dir", "fixtures", "录制目录")
+	retailer := fs.String("retailer", "jd", "零售商")
+	sku := fs.Int64("sku", 0, "商品编号")
+	config.Bind(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := loadConfig(fs); err != nil {
+		return err
+	}
+	spider.Client.Transport = &fixture.Recorder{Dir: *dir}
+	return probe(*retailer, *sku)
+}
+
+// cmdReplay 从录制目录回放，不访问网络
+func cmdReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	dir := fs.String("dir", "fixtures", "录制目录")
+	retailer := fs.String("retailer", "jd", "零售商")
+	sku := fs.Int64("sku", 0, "商品编号")
+	config.Bind(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := loadConfig(fs); err != nil {
+		return err
+	}
+	spider.Client.Transport = &fixture.Player{Dir: *dir}
+	spider.DefaultLimit, spider.Jitter = spider.Limit{}, 0
+	return probe(*retailer, *sku)
+}
+
+// cmdFakeJD 启动模拟京东服务器
+func cmdFakeJD(args []string) error {
+	fs := flag.NewFlagSet("fakejd", flag.ExitOnError)
+	addr := fs.String("addr", ":9090", "监听地址")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	log.Println("fakejd", *addr)
+	return http.ListenAndServe(*addr, fakejd.New(fakejd.Demo()...))
+}
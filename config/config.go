@@ -0,0 +1,224 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/panshiqu/shopping/pricing"
+	"github.com/panshiqu/shopping/region"
+)
+
+// Config 配置，优先级依次为命令行参数、环境变量、配置文件、默认值
+type Config struct {
+	DataSource    string `json:"dataSource"`    // 数据源，如 mysql://DSN、sqlite://shopping.db、memory://
+	Listen        string `json:"listen"`        // 监听地址
+	AdminPassword string `json:"adminPassword"` // 管理密码
+	PushURL       string `json:"pushURL"`       // 微信推送地址
+	Area          string `json:"area"`          // 默认配送区域
+	MultiArea     bool   `json:"multiArea"`     // 为订阅者所在的其他配送区域单独采样
+	MaxAreas      int    `json:"maxAreas"`      // 每个商品额外采样的区域数上限
+	MinPriority   int64  `json:"minPriority"`   // 最低优先级（刷新周期，秒）
+	PublicURL     string `json:"publicURL"`     // 对外地址
+	GameURL       string `json:"gameURL"`       // 游戏站点地址
+	Workers       int    `json:"workers"`       // 同时抓取的商品数
+	PricingMode   string `json:"pricingMode"`   // 计价模式
+}
+
+// Ins 实例
+var Ins = Default()
+
+// Default 默认配置
+func Default() *Config {
+	return &Config{
+		DataSource:    "mysql://root@tcp(localhost:3306)/shopping?charset=utf8mb4",
+		Listen:        ":8080",
+		AdminPassword: "161015",
+		PushURL:       "http://localhost/push",
+		Area:          "7_412_47301_0",
+		MaxAreas:      4,
+		MinPriority:   8 * 60 * 60,
+		PublicURL:     "http://www.iplaygame.com.cn:8080",
+		GameURL:       "http://www.iplaygame.com.cn:8081",
+		Workers:       4,
+		PricingMode:   pricing.ModeLegacy,
+	}
+}
+
+// setting 配置项，环境变量为 SHOPPING_ 加大写的参数名
+type setting struct {
+	name  string
+	usage string
+	ptr   func(c *Config) interface{}
+}
+
+var settings = []*setting{
+	{"dsn", "数据源，如 mysql://DSN、sqlite://shopping.db、memory://", func(c *Config) interface{} { return &c.DataSource }},
+	{"listen", "监听地址", func(c *Config) interface{} { return &c.Listen }},
+	{"admin-password", "管理密码", func(c *Config) interface{} { return &c.AdminPassword }},
+	{"push-url", "微信推送地址", func(c *Config) interface{} { return &c.PushURL }},
+	{"area", "默认配送区域（省_市_区_街道）", func(c *Config) interface{} { return &c.Area }},
+	{"multi-area", "为订阅者所在的其他配送区域单独采样", func(c *Config) interface{} { return &c.MultiArea }},
+	{"max-areas", "每个商品额外采样的区域数上限", func(c *Config) interface{} { return &c.MaxAreas }},
+	{"min-priority", "最低优先级（刷新周期，秒）", func(c *Config) interface{} { return &c.MinPriority }},
+	{"public-url", "对外地址", func(c *Config) interface{} { return &c.PublicURL }},
+	{"game-url", "游戏站点地址", func(c *Config) interface{} { return &c.GameURL }},
+	{"workers", "同时抓取的商品数", func(c *Config) interface{} { return &c.Workers }},
+	{"pricing-mode", "计价模式（legacy、stack），切换后需执行 reprocess 重算已保存的价格", func(c *Config) interface{} { return &c.PricingMode }},
+}
+
+func (s *setting) env() string {
+	return "SHOPPING_" + strings.ToUpper(strings.ReplaceAll(s.name, "-", "_"))
+}
+
+func (s *setting) set(c *Config, in string) error {
+	var err error
+	switch p := s.ptr(c).(type) {
+	case *string:
+		*p = in
+	case *bool:
+		*p, err = strconv.ParseBool(in)
+	case *int:
+		*p, err = strconv.Atoi(in)
+	case *int64:
+		*p, err = strconv.ParseInt(in, 10, 64)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", s.name, err)
+	}
+	return nil
+}
+
+// value 命令行参数，解析时只记录，Load 时在配置文件及环境变量之后应用
+type value struct {
+	s *setting
+	v string
+}
+
+func (v *value) String() string {
+	return v.v
+}
+
+func (v *value) Set(in string) error {
+	v.v = in
+	return v.s.set(Default(), in)
+}
+
+func (v *value) IsBoolFlag() bool {
+	_, ok := v.s.ptr(Default()).(*bool)
+	return ok
+}
+
+// Bind 在 fs 上注册各配置项及 -config 参数
+func Bind(fs *flag.FlagSet) {
+	fs.String("config", "", "配置文件（JSON），也可由环境变量 SHOPPING_CONFIG 指定")
+	for _, v := range settings {
+		fs.Var(&value{s: v}, v.name, fmt.Sprintf("%s（环境变量 %s）", v.usage, v.env()))
+	}
+}
+
+// Load 依次应用配置文件、环境变量及 fs 上已解析的参数并校验，结果保存在 Ins
+func Load(fs *flag.FlagSet) error {
+	c := Default()
+
+	file := os.Getenv("SHOPPING_CONFIG")
+	if f := fs.Lookup("config"); f != nil && f.Value.String() != "" {
+		file = f.Value.String()
+	}
+	if file != "" {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(body, c); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+	}
+
+	for _, v := range settings {
+		if e, ok := os.LookupEnv(v.env()); ok {
+			if err := v.set(c, e); err != nil {
+				return err
+			}
+		}
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		if v, ok := f.Value.(*value); ok && err == nil {
+			err = v.s.set(c, v.v)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	Ins = c
+	return nil
+}
+
+// Validate 校验
+func (c *Config) Validate() error {
+	var errs []string
+	if c.DataSource == "" {
+		errs = append(errs, "dsn 不能为空")
+	}
+	if c.Listen == "" {
+		errs = append(errs, "listen 不能为空")
+	}
+	if c.AdminPassword == "" {
+		errs = append(errs, "admin-password 不能为空")
+	}
+	for _, v := range []struct{ name, url string }{{"push-url", c.PushURL}, {"public-url", c.PublicURL}, {"game-url", c.GameURL}} {
+		if u, err := url.Parse(v.url); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, v.name+" 不是有效的地址")
+		}
+	}
+	if c.Area == "" || region.Check(c.Area) != nil {
+		errs = append(errs, "area 应形如 省_市_区_街道")
+	}
+	if c.MaxAreas < 0 {
+		errs = append(errs, "max-areas 不能小于零")
+	}
+	if c.MinPriority <= 0 {
+		errs = append(errs, "min-priority 应大于零")
+	}
+	if c.Workers <= 0 {
+		errs = append(errs, "workers 应大于零")
+	}
+	if !contains(pricing.Modes(), c.PricingMode) {
+		errs = append(errs, "pricing-mode 应为 "+strings.Join(pricing.Modes(), "、")+" 之一")
+	}
+	if len(errs) != 0 {
+		return errors.New("config: " + strings.Join(errs, "；"))
+	}
+	return nil
+}
+
+func contains(in []string, v string) bool {
+	for _, s := range in {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Print 输出生效的配置，隐藏管理密码
+func (c *Config) Print(w io.Writer) error {
+	out := *c
+	out.AdminPassword = strings.Repeat("*", len(out.AdminPassword))
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(&out)
+}
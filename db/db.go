@@ -2,22 +2,62 @@ package db
 
 import (
 	"database/sql"
-	"log"
+	"strings"
 )
 
-const dataSource = "root@tcp(localhost:3306)/shopping?charset=utf8mb4"
+// 驱动
+const (
+	MySQL  = "mysql"
+	SQLite = "sqlite"
+)
 
 // Ins 实例
 var Ins *sql.DB
 
-func init() {
-	var err error
+// Driver 实例使用的驱动
+var Driver = MySQL
+
+// Execer 连接或事务
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Open 打开数据库并检查连接
+func Open(driver, dataSource string) error {
+	ins, err := sql.Open(driver, dataSource)
+	if err != nil {
+		return err
+	}
 
-	if Ins, err = sql.Open("mysql", dataSource); err != nil {
-		log.Fatal(err)
+	if err := ins.Ping(); err != nil {
+		ins.Close()
+		return err
 	}
 
-	if err = Ins.Ping(); err != nil {
-		log.Fatal(err)
+	Ins, Driver = ins, driver
+	return nil
+}
+
+// InsertIgnore 忽略唯一键冲突的插入
+func InsertIgnore() string {
+	if Driver == SQLite {
+		return "INSERT OR IGNORE"
+	}
+	return "INSERT IGNORE"
+}
+
+// Upsert 主键 keys 冲突时以新值更新 cols，追加在 INSERT ... VALUES (...) 之后
+func Upsert(keys string, cols ...string) string {
+	set := make([]string, len(cols))
+	for k, v := range cols {
+		if Driver == SQLite {
+			set[k] = v + " = excluded." + v
+		} else {
+			set[k] = v + " = VALUES(" + v + ")"
+		}
+	}
+	if Driver == SQLite {
+		return "ON CONFLICT (" + keys + ") DO UPDATE SET " + strings.Join(set, ",")
 	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(set, ",")
 }
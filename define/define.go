@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 )
 
 // ErrDataSame .
@@ -28,26 +29,465 @@ var ErrIllegalAlias = errors.New("illegal alias")
 // ErrIllegalPassword .
 var ErrIllegalPassword = errors.New("illegal password")
 
+// ErrUnknownRetailer .
+var ErrUnknownRetailer = errors.New("unknown retailer")
+
+// ErrUnauthorized .
+var ErrUnauthorized = errors.New("Unauthorized")
+
+// ErrMethodNotAllowed .
+var ErrMethodNotAllowed = errors.New("Method Not Allowed")
+
+// ErrIllegalBucket .
+var ErrIllegalBucket = errors.New("illegal bucket")
+
+// ErrUnknownMode .
+var ErrUnknownMode = errors.New("Unknown Mode")
+
+// ErrIllegalRule .
+var ErrIllegalRule = errors.New("illegal rule")
+
+// ErrCircuitOpen .
+var ErrCircuitOpen = errors.New("circuit open")
+
+// ErrUnknownChannel .
+var ErrUnknownChannel = errors.New("Unknown Channel")
+
+// ErrNotConfigured .
+var ErrNotConfigured = errors.New("Not Configured")
+
+// ErrIllegalTarget .
+var ErrIllegalTarget = errors.New("illegal target")
+
+// ErrIllegalPurchase .
+var ErrIllegalPurchase = errors.New("illegal purchase")
+
+// ErrUnknownDataSource .
+var ErrUnknownDataSource = errors.New("Unknown Data Source")
+
+// ErrIllegalArea .
+var ErrIllegalArea = errors.New("illegal area")
+
+// ErrEmptyCart .
+var ErrEmptyCart = errors.New("empty cart")
+
+// ErrIllegalCSRF .
+var ErrIllegalCSRF = errors.New("illegal csrf")
+
+// ErrIllegalCaptcha .
+var ErrIllegalCaptcha = errors.New("illegal captcha")
+
+// ErrTooManyAttempts .
+var ErrTooManyAttempts = errors.New("too many attempts")
+
+// 提醒规则
+const (
+	RuleMinPrice    = iota // 历史最低价
+	RuleTargetPrice        // 目标价
+	RulePercentDrop        // 较订阅时降幅
+	RuleAverage            // 低于N日均价
+	RuleRestock            // 到货
+	RuleDelist             // 下柜
+)
+
+// 库存状态
+const (
+	StockOffShelf = iota // 下柜
+	StockInStock         // 有货
+)
+
+// StockOf 价格为负表示商品下柜
+func StockOf(price float64) int64 {
+	if price < 0 {
+		return StockOffShelf
+	}
+	return StockInStock
+}
+
+// 促销变化提醒
+const (
+	PromAlertCoupon    = 1 << iota // 新优惠券
+	PromAlertReduction             // 新满减
+	PromAlertGift                  // 新赠品
+	PromAlertOther                 // 其它促销
+)
+
+// PromAlertOf 促销类型对应的促销变化提醒
+func PromAlertOf(typ string) int64 {
+	switch typ {
+	case PromCoupon, PromStepCoupon:
+		return PromAlertCoupon
+	case PromFullReduction, PromPickN, PromMultiBuy:
+		return PromAlertReduction
+	case PromGift:
+		return PromAlertGift
+	}
+	return PromAlertOther
+}
+
 // IndexData 首页数据
 type IndexData struct {
-	Args  []*IndexArgs
-	Alias string
-	Proms map[string]int
+	Args  []*IndexArgs   `json:"args"`
+	Alias string         `json:"alias,omitempty"`
+	Proms map[string]int `json:"proms"`
+	Login string         `json:"-"` // 登录用户的别名
+	Share bool           `json:"-"` // 登录用户是否开启分享
+	Token string         `json:"-"` // 通过分享令牌访问时的令牌
+	CSRF  string         `json:"-"`
 }
 
 // IndexArgs 首页参数
 type IndexArgs struct {
-	SkuID     int64
-	Price     float64
-	Content   string
-	MinPrice  float64
-	MaxPrice  float64
-	Timestamp string
-	Duration  string
-	Sampling  int64
-	Name      string
+	SkuID       int64        `json:"sku"`
+	Price       float64      `json:"price"`
+	Promotions  []*Promotion `json:"promotions"`
+	Breakdown   *Breakdown   `json:"breakdown"`
+	MinPrice    float64      `json:"minPrice"`
+	MaxPrice    float64      `json:"maxPrice"`
+	Timestamp   string       `json:"timestamp"`
+	Duration    string       `json:"duration"`
+	Sampling    int64        `json:"sampling"`
+	Name        string       `json:"name"`
+	Retailer    string       `json:"retailer"`
+	Stock       int64        `json:"stock"`
+	URL         string       `json:"url"`
+	Src         string       `json:"src"`
+	KoBeginTime int64        `json:"koBeginTime"`
+	KoEndTime   int64        `json:"koEndTime"`
+
+	InsertTimestamp int64 `json:"insertTimestamp"`
+}
+
+// 促销类型
+const (
+	PromCoupon        = "coupon"         // 满减券
+	PromStepCoupon    = "step_coupon"    // 阶梯券
+	PromAd            = "ad"             // 广告语
+	PromQuan          = "quan"           // 满额返券
+	PromFullReduction = "full_reduction" // 满减
+	PromPickN         = "pick_n"         // N元选M件
+	PromMultiBuy      = "multi_buy"      // 多买优惠
+	PromGift          = "gift"           // 赠品
+	PromTag           = "tag"            // 其它促销标签
+	PromUnknown       = "unknown"        // 未知
+)
+
+// Promotion 促销
+type Promotion struct {
+	Type      string  `json:"type"`
+	Code      string  `json:"code,omitempty"` // 促销标签编号
+	Pid       string  `json:"pid,omitempty"`  // 促销编号
+	Text      string  `json:"text"`
+	URL       string  `json:"url,omitempty"`
+	Threshold float64 `json:"threshold"` // 满额门槛，N元选M件时为N元
+	Discount  float64 `json:"discount"`  // 减额
+	Count     int64   `json:"count"`     // 件数
+	Rate      float64 `json:"rate"`      // 折扣率，为零表示无法换算
+	Begin     string  `json:"begin,omitempty"`
+	End       string  `json:"end,omitempty"`
+	Each      bool    `json:"each,omitempty"`      // 每满
+	Cap       float64 `json:"cap,omitempty"`       // 最多可减
+	Stackable bool    `json:"stackable,omitempty"` // 优惠券可与促销叠加
+	Tiers     []*Tier `json:"tiers,omitempty"`     // 阶梯券档位
+}
 
-	InsertTimestamp int64
+// Tier 阶梯券档位
+type Tier struct {
+	Quota    float64 `json:"quota"`
+	Discount float64 `json:"discount"`
+}
+
+func (p *Promotion) String() string {
+	return p.Text
+}
+
+// Breakdown 价格构成
+type Breakdown struct {
+	Mode       string  `json:"mode"`       // 计价模式
+	Quantity   int64   `json:"quantity"`   // 达到到手价所需件数
+	ListPrice  float64 `json:"listPrice"`  // 标价
+	Coupon     string  `json:"coupon"`     // 采用的优惠券
+	CouponRate float64 `json:"couponRate"` // 优惠券折扣率
+	Tag        string  `json:"tag"`        // 采用的促销标签
+	TagRate    float64 `json:"tagRate"`    // 促销标签折扣率
+	Discounted float64 `json:"discounted"` // 折后价
+	Tax        float64 `json:"tax"`        // 税费
+	Rounding   float64 `json:"rounding"`   // 四舍五入差额
+	Price      float64 `json:"price"`      // 到手价
+
+	Gifts   []string   `json:"gifts,omitempty"`   // 赠品
+	Compare *Breakdown `json:"compare,omitempty"` // 其它计价模式的结果，用于对比
+}
+
+// Round 折后价加税费并四舍五入到分，记录差额
+func (b *Breakdown) Round() float64 {
+	b.Price = math.Trunc((b.Discounted+b.Tax)*100+0.5) / 100
+	b.Rounding = b.Price - (b.Discounted + b.Tax)
+	if b.Compare != nil {
+		b.Compare.Tax = b.Tax
+		b.Compare.Round()
+	}
+	return b.Price
+}
+
+func (b *Breakdown) String() string {
+	var buf bytes.Buffer
+	if b.Mode != "" {
+		fmt.Fprintf(&buf, "[%s] ", b.Mode)
+	}
+	if b.Quantity > 1 {
+		fmt.Fprintf(&buf, "买%d件 ", b.Quantity)
+	}
+	fmt.Fprintf(&buf, "标价%.2f", b.ListPrice)
+	if b.Coupon != "" {
+		fmt.Fprintf(&buf, " × %.4f（%s）", b.CouponRate, b.Coupon)
+	}
+	if b.Tag != "" {
+		fmt.Fprintf(&buf, " × %.4f（%s）", b.TagRate, b.Tag)
+	}
+	if b.Tax != 0 {
+		fmt.Fprintf(&buf, " + 税费%.2f", b.Tax)
+	}
+	fmt.Fprintf(&buf, " = %.4f，四舍五入%+.4f = %.2f", b.Discounted+b.Tax, b.Rounding, b.Price)
+	for _, v := range b.Gifts {
+		fmt.Fprintf(&buf, " 赠%s", v)
+	}
+	return buf.String()
+}
+
+// User 用户
+type User struct {
+	ID       string `json:"id"`
+	Alias    string `json:"alias"`
+	Password string `json:"-"`
+	Area     string `json:"area,omitempty"` // 配送区域，为空时使用默认区域
+	Admin    bool   `json:"admin,omitempty"`
+	Share    string `json:"-"` // 分享令牌摘要，为空时未开启分享
+}
+
+// Session 登录会话
+type Session struct {
+	Token  string `json:"-"`
+	ID     string `json:"id"`
+	CSRF   string `json:"csrf"`
+	Expire int64  `json:"expire"`
+}
+
+// SKU 商品
+type SKU struct {
+	SkuID           int64   `json:"sku"`
+	Priority        int64   `json:"priority"`
+	MinPrice        float64 `json:"minPrice"`
+	MaxPrice        float64 `json:"maxPrice"`
+	Retailer        string  `json:"retailer"`
+	Stock           int64   `json:"stock"`
+	InsertTimestamp int64   `json:"insertTimestamp"`
+}
+
+// Subscription 订阅
+type Subscription struct {
+	ID        string  `json:"-"`
+	SkuID     int64   `json:"sku"`
+	Keywords  string  `json:"keywords"`
+	Rule      int64   `json:"rule"`
+	Target    float64 `json:"target"` // 目标价或降幅百分比
+	Days      int64   `json:"days"`
+	BasePrice float64 `json:"basePrice"`      // 订阅时价格
+	PromAlert int64   `json:"promAlert"`      // 促销变化提醒
+	Area      string  `json:"area,omitempty"` // 配送区域，为空时使用用户或部署的默认区域
+}
+
+// Channel 通知渠道
+type Channel struct {
+	Type   string `json:"type"`
+	Target string `json:"target"`
+}
+
+// 通知投递状态
+const (
+	NotifyPending   = iota // 待投递
+	NotifyDelivered        // 已投递
+	NotifyFailed           // 投递失败
+)
+
+// Notification 通知投递记录
+type Notification struct {
+	ID          int64  `json:"id"`
+	UserID      string `json:"-"`
+	SkuID       int64  `json:"sku"`
+	Type        string `json:"type"`
+	Target      string `json:"-"`
+	Message     string `json:"message"`
+	Status      int64  `json:"status"`
+	Attempts    int64  `json:"attempts"`
+	LastError   string `json:"lastError"`
+	NextAttempt int64  `json:"nextAttempt"`
+	CreateTime  int64  `json:"createTime"`
+	DeliverTime int64  `json:"deliverTime"`
+	Dedupe      string `json:"-"` // 去重键
+}
+
+// Purchase 购买记录
+type Purchase struct {
+	ID            int64   `json:"id"`
+	UserID        string  `json:"-"`
+	SkuID         int64   `json:"sku"`
+	Price         float64 `json:"price"`
+	Quantity      int64   `json:"quantity"`
+	PurchaseTime  int64   `json:"purchaseTime"`
+	Days          int64   `json:"days"`          // 价保天数
+	NotifiedPrice float64 `json:"notifiedPrice"` // 已提醒的价格
+}
+
+// Change 采样变化
+type Change struct {
+	Push           bool         // 创历史新低或回到历史最低价
+	PrevPrice      float64      // 上次价格，为零表示首次采样
+	PrevPromotions []*Promotion // 上次促销
+}
+
+// RegionPrice 商品在某配送区域的最新价格及促销
+type RegionPrice struct {
+	SkuID      int64        `json:"sku"`
+	Area       string       `json:"area"`
+	Price      float64      `json:"price"`
+	MinPrice   float64      `json:"minPrice"`
+	Stock      int64        `json:"stock"`
+	Breakdown  *Breakdown   `json:"breakdown,omitempty"`
+	Promotions []*Promotion `json:"promotions,omitempty"`
+	UpdateTime int64        `json:"updateTime"`
+}
+
+// Alert 提醒
+type Alert struct {
+	ID      string
+	SkuID   int64
+	Message string
+}
+
+// Sample 采样
+type Sample struct {
+	Price     float64    `json:"price"`
+	Stock     int64      `json:"stock"`
+	Timestamp int64      `json:"timestamp"`
+	Breakdown *Breakdown `json:"breakdown,omitempty"`
+}
+
+// Record 采样记录，含原始数据以便重放
+type Record struct {
+	ID          int64
+	SkuID       int64
+	Price       float64
+	Stock       int64
+	Breakdown   []byte // 价格构成 JSON
+	Promotions  []*Promotion
+	JDPrice     []byte
+	JDPromotion []byte
+	PageConfig  []byte
+	KoBeginTime int64
+	KoEndTime   int64
+	Timestamp   int64
+}
+
+// Bucket 降采样桶
+type Bucket struct {
+	Timestamp    int64   `json:"timestamp"`
+	Min          float64 `json:"min"`
+	MinTimestamp int64   `json:"minTimestamp"`
+	Max          float64 `json:"max"`
+	MaxTimestamp int64   `json:"maxTimestamp"`
+	Last         float64 `json:"last"`
+	Count        int64   `json:"count"`
+}
+
+// Window 秒杀时间段
+type Window struct {
+	Begin int64 `json:"begin"`
+	End   int64 `json:"end"`
+}
+
+// History 价格历史
+type History struct {
+	SkuID        int64     `json:"sku"`
+	Begin        int64     `json:"begin"`
+	End          int64     `json:"end"`
+	Bucket       string    `json:"bucket,omitempty"`
+	Min          float64   `json:"min"`
+	MinTimestamp int64     `json:"minTimestamp"`
+	Max          float64   `json:"max"`
+	MaxTimestamp int64     `json:"maxTimestamp"`
+	Samples      []*Sample `json:"samples,omitempty"`
+	Buckets      []*Bucket `json:"buckets,omitempty"`
+}
+
+// CartLine 购物车商品
+type CartLine struct {
+	SkuID    int64   `json:"sku"`
+	Name     string  `json:"name"`
+	Price    float64 `json:"price"` // 标价
+	Quantity int64   `json:"quantity"`
+}
+
+// CartGroup 凑单分组，Promotion 为空表示不参与促销
+type CartGroup struct {
+	Promotion string      `json:"promotion"`
+	Pid       string      `json:"pid,omitempty"`
+	Lines     []*CartLine `json:"lines"`
+	Subtotal  float64     `json:"subtotal"`
+	Reduction float64     `json:"reduction"`
+	Total     float64     `json:"total"`
+}
+
+// CartPlan 购物车方案
+type CartPlan struct {
+	Groups      []*CartGroup `json:"groups"`
+	Unavailable []int64      `json:"unavailable,omitempty"` // 已下柜或无价格
+	ListTotal   float64      `json:"listTotal"`
+	Total       float64      `json:"total"`
+	Saving      float64      `json:"saving"`
+}
+
+// FieldHealth 字段解析健康状况
+type FieldHealth struct {
+	Field       string  `json:"field"`
+	Total       int64   `json:"total"`
+	Failed      int64   `json:"failed"`
+	Window      int     `json:"window"` // 参与计算成功率的最近解析次数
+	Rate        float64 `json:"rate"`   // 最近解析成功率
+	LastFailure int64   `json:"lastFailure,omitempty"`
+	LastError   string  `json:"lastError,omitempty"`
+	SkuID       int64   `json:"sku,omitempty"`    // 最近失败的商品
+	Sample      string  `json:"sample,omitempty"` // 最近失败的原始数据片段
+}
+
+// SpiderStats 抓取队列统计
+type SpiderStats struct {
+	Workers    int          `json:"workers"`
+	QueueDepth int          `json:"queueDepth"`
+	QueueSize  int          `json:"queueSize"`
+	InFlight   int64        `json:"inFlight"`
+	Enqueued   int64        `json:"enqueued"`
+	Skipped    int64        `json:"skipped"` // 已在队列或抓取中
+	Dropped    int64        `json:"dropped"` // 队列满
+	Processed  int64        `json:"processed"`
+	Failed     int64        `json:"failed"`
+	Open       int          `json:"open"`    // 熔断中的商品数
+	Tripped    int64        `json:"tripped"` // 累计熔断次数
+	Hosts      []*HostStats `json:"hosts"`
+}
+
+// HostStats 域名限速统计
+type HostStats struct {
+	Host     string  `json:"host"`
+	Rate     float64 `json:"rate"`
+	Burst    float64 `json:"burst"`
+	Requests int64   `json:"requests"`
+	Waited   int64   `json:"waited"` // 累计限速等待毫秒数
+}
+
+// IsOffShelf .
+func (i *IndexArgs) IsOffShelf() bool {
+	return i.Stock == StockOffShelf
 }
 
 // IsMinPrice .
@@ -55,6 +495,21 @@ func (i *IndexArgs) IsMinPrice() bool {
 	return i.MinPrice != i.MaxPrice && i.MinPrice == i.Price
 }
 
+// Page 商品页面
+type Page struct {
+	SkuID       int64
+	Name        string
+	URL         string
+	Src         string
+	KoBeginTime int64
+	KoEndTime   int64
+
+	Area    string      // 配送区域，为空时使用部署的默认区域
+	Raw     []byte      // 原始页面配置
+	Config  interface{} // 零售商页面配置
+	Missing []string    // 页面配置中缺失的字段
+}
+
 // JDPageConfig 页面配置
 type JDPageConfig struct {
 	SkuID       int64
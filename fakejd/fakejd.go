@@ -0,0 +1,237 @@
+package fakejd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/panshiqu/shopping/define"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// Item 模拟商品
+type Item struct {
+	SkuID       int64
+	Name        string
+	Price       string // -1.00 表示已下柜
+	Src         string
+	Cat         []int64
+	KoBeginTime int64
+	KoEndTime   int64
+	Coupons     []*define.JDSkuCoupon
+	Ads         []*define.JDAds
+	Quans       []*define.JDQuan
+	Tags        []*define.JDTag
+	Tax         string            // 为空表示非全球购
+	Areas       map[string]string // 各配送区域的价格，未列出的区域使用 Price
+
+	Want float64 // 期望的到手价，用于离线校验
+}
+
+// Server 模拟京东商品页、价格、促销及税费接口，页面及促销按 GBK 编码返回
+type Server struct {
+	mtx   sync.RWMutex
+	items map[int64]*Item
+}
+
+// New 创建
+func New(items ...*Item) *Server {
+	s := &Server{items: make(map[int64]*Item)}
+	for _, v := range items {
+		s.Set(v)
+	}
+	return s
+}
+
+// Set 增加或替换商品
+func (s *Server) Set(in *Item) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.items[in.SkuID] = in
+}
+
+// Items 全部商品，按编号排序
+func (s *Server) Items() (out []*Item) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	for _, v := range s.items {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].SkuID < out[j].SkuID })
+	return
+}
+
+func (s *Server) item(in string) (*Item, bool) {
+	sku, err := strconv.ParseInt(strings.TrimPrefix(in, "J_"), 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	v, ok := s.items[sku]
+	return v, ok
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+	var err error
+
+	switch r.URL.Path {
+	case "/prices/mgets":
+		v, ok := s.item(r.FormValue("skuIds"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		price := v.Price
+		if p, ok := v.Areas[r.FormValue("area")]; ok {
+			price = p
+		}
+		body, err = json.Marshal([]*define.JDPrice{{Price: price, OriginPrice: price}})
+	case "/promotion/v2":
+		v, ok := s.item(r.FormValue("skuId"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		body, err = promotion(v)
+	case "/globalBuy":
+		v, ok := s.item(r.FormValue("skuId"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		body, err = tax(v)
+	default:
+		v, ok := s.item(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".html"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		body, err = Page(v)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(body)
+}
+
+func gbk(in []byte) ([]byte, error) {
+	return simplifiedchinese.GBK.NewEncoder().Bytes(in)
+}
+
+// Page 商品页
+func Page(in *Item) ([]byte, error) {
+	name, err := json.Marshal(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	var cat []string
+	for _, v := range in.Cat {
+		cat = append(cat, strconv.FormatInt(v, 10))
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<html><head><meta charset=\"gbk\" /><script>\nvar pageConfig = {\n\tcompatible: true,\n\tproduct: {\n\t\tskuid: %d,\n\t\tname: %s,\n\t\tkoBeginTime: %d,\n\t\tkoEndTime: %d,\n\t\tsrc: '%s',\n\t\tcat: [%s]\n\t}\n};\n</script></head><body></body></html>",
+		in.SkuID, name, in.KoBeginTime, in.KoEndTime, in.Src, strings.Join(cat, ","))
+	return gbk(buf.Bytes())
+}
+
+func promotion(in *Item) ([]byte, error) {
+	quans := in.Quans
+	if quans == nil {
+		quans = []*define.JDQuan{}
+	}
+	tags := in.Tags
+	if tags == nil {
+		tags = []*define.JDTag{}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"quan":      quans,
+		"skuCoupon": in.Coupons,
+		"ads":       in.Ads,
+		"prom": map[string]interface{}{
+			"pickOneTag": []*define.JDTag{},
+			"tags":       tags,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return gbk(body)
+}
+
+func tax(in *Item) ([]byte, error) {
+	body, err := json.Marshal(&define.JDGlobalBuy{
+		Success: in.Tax != "",
+		TaxTxt:  &define.JDTaxTxt{Content: "进口税：￥" + in.Tax},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return gbk(body)
+}
+
+// Demo 覆盖常见促销的示例商品
+func Demo() []*Item {
+	return []*Item{
+		{
+			SkuID: 100001,
+			Name:  "示例商品（无促销）",
+			Src:   "jfs/t1/demo1.jpg",
+			Price: "99.00",
+			Cat:   []int64{1, 2, 3},
+			Want:  99,
+		},
+		{
+			SkuID: 100002,
+			Name:  "示例商品（满减叠加优惠券）",
+			Src:   "jfs/t1/demo2.jpg",
+			Price: "299.00",
+			Cat:   []int64{1, 2, 3},
+			Coupons: []*define.JDSkuCoupon{
+				{Quota: 199, Discount: 20, Name: "限品类东券", OverlapDesc: "可与促销叠加"},
+			},
+			Tags: []*define.JDTag{
+				{Code: "15", Name: "满减", Content: "满299元减50元", Pid: "9001"},
+			},
+			Want: 229,
+		},
+		{
+			SkuID: 100003,
+			Name:  "示例商品（多买优惠及赠品）",
+			Src:   "jfs/t1/demo3.jpg",
+			Price: "50.00",
+			Cat:   []int64{1, 2, 4},
+			Tags: []*define.JDTag{
+				{Code: "19", Name: "多买优惠", Content: "满2件，总价打8折", Pid: "9002"},
+				{Code: "10", Name: "赠品", Pid: "9003", Gifts: []*define.JDGift{{Nm: "示例赠品", Sid: "100009", Num: 1}}},
+			},
+			Want: 40, // 买2件打8折
+		},
+		{
+			SkuID: 100004,
+			Name:  "示例商品（全球购）",
+			Src:   "jfs/t1/demo4.jpg",
+			Price: "100.00",
+			Cat:   []int64{1, 2, 5},
+			Tax:   "9.10",
+			Want:  109.1, // 含税费
+		},
+		{
+			SkuID: 100005,
+			Name:  "示例商品（已下柜）",
+			Src:   "jfs/t1/demo5.jpg",
+			Price: "-1.00",
+			Cat:   []int64{1, 2, 6},
+			Want:  -0.99, // 下柜价格-1经四舍五入
+		},
+	}
+}
@@ -0,0 +1,138 @@
+package fixture
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Volatile 每次请求都会变化、不参与匹配的查询参数
+var Volatile = map[string]bool{"pduid": true}
+
+// Response 录制的响应，正文按原始字节保存（含 GBK 页面）
+type Response struct {
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// Key 请求对应的录制文件名
+func Key(u *url.URL) string {
+	q := u.Query()
+	var keys []string
+	for k := range q {
+		if !Volatile[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteString(u.Host)
+	buf.WriteString(u.Path)
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "&%s=%s", k, strings.Join(q[k], ","))
+	}
+
+	sum := sha1.Sum(buf.Bytes())
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, buf.String())
+	if len(name) > 96 {
+		name = name[:96]
+	}
+	return name + "-" + hex.EncodeToString(sum[:4]) + ".json"
+}
+
+// Recorder 转发请求并把响应保存到目录
+type Recorder struct {
+	Dir       string
+	Transport http.RoundTripper // 为空时使用 http.DefaultTransport
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	t := r.Transport
+	if t == nil {
+		t = http.DefaultTransport
+	}
+
+	resp, err := t.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := json.MarshalIndent(&Response{
+		URL:    req.URL.String(),
+		Status: resp.StatusCode,
+		Header: resp.Header,
+		Body:   body,
+	}, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(r.Dir, Key(req.URL)), out, 0644); err != nil {
+		return nil, err
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// Player 从目录回放录制的响应，不访问网络
+type Player struct {
+	Dir string
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	in, err := ioutil.ReadFile(filepath.Join(p.Dir, Key(req.URL)))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("fixture not found: %s", req.URL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Response{}
+	if err := json.Unmarshal(in, r); err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,167 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/outbox"
+	"github.com/panshiqu/shopping/store"
+)
+
+var (
+	// Window 按最近多少次解析计算成功率
+	Window = 100
+
+	// MinSamples 窗口内至少多少次解析才判断
+	MinSamples = 20
+
+	// Threshold 成功率低于该值时提醒管理员
+	Threshold = 0.9
+
+	// Cooldown 同一字段两次提醒的最小间隔
+	Cooldown = 6 * time.Hour
+
+	// MaxSample 提醒附带原始数据的最大字节数
+	MaxSample = 512
+)
+
+type counter struct {
+	results []bool // 最近的解析结果，环形
+	next    int
+	total   int64
+	failed  int64
+
+	lastFailure int64
+	lastError   string
+	sku         int64
+	sample      []byte
+	lastAlert   time.Time
+}
+
+func (c *counter) rate() (float64, int) {
+	var ok int
+	for _, v := range c.results {
+		if v {
+			ok++
+		}
+	}
+	if len(c.results) == 0 {
+		return 1, 0
+	}
+	return float64(ok) / float64(len(c.results)), len(c.results)
+}
+
+var (
+	mtx      sync.Mutex
+	counters = make(map[string]*counter)
+)
+
+// Record 记录字段解析结果，失败时保留原始数据，成功率下降时提醒管理员
+func Record(field string, sku int64, err error, payload []byte) {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	c, ok := counters[field]
+	if !ok {
+		c = &counter{}
+		counters[field] = c
+	}
+
+	if len(c.results) < Window {
+		c.results = append(c.results, err == nil)
+	} else {
+		c.results[c.next] = err == nil
+		c.next = (c.next + 1) % Window
+	}
+	c.total++
+
+	if err == nil {
+		return
+	}
+
+	c.failed++
+	c.lastFailure = time.Now().Unix()
+	c.lastError = err.Error()
+	c.sku = sku
+	c.sample = truncate(payload)
+
+	rate, n := c.rate()
+	if n < MinSamples || rate >= Threshold || time.Since(c.lastAlert) < Cooldown {
+		return
+	}
+
+	c.lastAlert = time.Now()
+	message := fmt.Sprintf("【页面结构可能变化】%s 最近%d次解析成功率%.0f%%，商品%d：%s\n%s", field, n, rate*100, sku, c.lastError, c.sample)
+	go func() {
+		if err := notifyAdmins(sku, message); err != nil {
+			log.Println("notifyAdmins", field, err)
+		}
+	}()
+}
+
+// Check 以布尔结果记录字段解析
+func Check(field string, sku int64, ok bool, payload []byte) {
+	var err error
+	if !ok {
+		err = fmt.Errorf("illegal %s", field)
+	}
+	Record(field, sku, err, payload)
+}
+
+func truncate(in []byte) []byte {
+	if len(in) > MaxSample {
+		in = in[:MaxSample]
+	}
+	return []byte(strings.ToValidUTF8(string(in), ""))
+}
+
+func notifyAdmins(sku int64, message string) error {
+	admins, err := store.Ins.Admins()
+	if err != nil {
+		return err
+	}
+
+	var alerts []*define.Alert
+	for _, v := range admins {
+		alerts = append(alerts, &define.Alert{ID: v, SkuID: sku, Message: message})
+	}
+
+	if len(alerts) == 0 {
+		log.Println("notifyAdmins no admin", message)
+		return nil
+	}
+
+	return store.Ins.Atomic(context.Background(), func(tx store.Tx) error {
+		return outbox.Enqueue(tx, alerts)
+	})
+}
+
+// Stats 各字段解析健康状况
+func Stats() []*define.FieldHealth {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	out := []*define.FieldHealth{}
+	for k, v := range counters {
+		rate, n := v.rate()
+		out = append(out, &define.FieldHealth{
+			Field:       k,
+			Total:       v.total,
+			Failed:      v.failed,
+			Window:      n,
+			Rate:        rate,
+			LastFailure: v.lastFailure,
+			LastError:   v.lastError,
+			SkuID:       v.sku,
+			Sample:      string(v.sample),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Field < out[j].Field })
+	return out
+}
@@ -0,0 +1,157 @@
+package history
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/store"
+)
+
+// Select 查询 [begin, end) 时间范围内的采样
+func Select(sku, begin, end int64) ([]*define.Sample, error) {
+	rs, err := store.Ins.Samples(sku, begin, end)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []*define.Sample{}
+	for _, v := range rs {
+		s := &define.Sample{Price: v.Price, Stock: v.Stock, Timestamp: v.Timestamp}
+
+		if len(v.Breakdown) != 0 {
+			s.Breakdown = &define.Breakdown{}
+			if err := json.Unmarshal(v.Breakdown, s.Breakdown); err != nil {
+				return nil, err
+			}
+		}
+
+		out = append(out, s)
+	}
+
+	return out, nil
+}
+
+// Windows 查询 [begin, end) 时间范围内的秒杀时间段
+func Windows(sku, begin, end int64) ([]*define.Window, error) {
+	rs, err := store.Ins.Samples(sku, begin, end)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		koBegin, koEnd int64
+	}
+
+	var keys []key
+	first := make(map[key]int64)
+	last := make(map[key]int64)
+	for _, v := range rs {
+		if v.KoBeginTime == 0 && v.KoEndTime == 0 {
+			continue
+		}
+		k := key{v.KoBeginTime, v.KoEndTime}
+		if _, ok := first[k]; !ok {
+			keys = append(keys, k)
+			first[k] = v.Timestamp
+		}
+		last[k] = v.Timestamp
+	}
+
+	var out []*define.Window
+	for _, k := range keys {
+		// 仅知道开始或结束时间时以采样时间补齐
+		w := &define.Window{Begin: first[k], End: last[k]}
+		if k.koBegin != 0 {
+			w.Begin = k.koBegin / 1000
+		}
+		if k.koEnd != 0 {
+			w.End = k.koEnd / 1000
+		}
+		if w.End < w.Begin {
+			w.End = w.Begin
+		}
+
+		out = append(out, w)
+	}
+
+	return out, nil
+}
+
+// Truncate 按桶截断时间戳
+func Truncate(timestamp int64, bucket string) (int64, error) {
+	t := time.Unix(timestamp, 0)
+	switch bucket {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()).Unix(), nil
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Unix(), nil
+	}
+	return 0, define.ErrIllegalBucket
+}
+
+// Downsample 降采样，每桶保留最低价、最高价、最后价格及发生时间
+func Downsample(in []*define.Sample, bucket string) ([]*define.Bucket, error) {
+	out := []*define.Bucket{}
+	var last *define.Bucket
+	for _, v := range in {
+		ts, err := Truncate(v.Timestamp, bucket)
+		if err != nil {
+			return nil, err
+		}
+		if last == nil || last.Timestamp != ts {
+			last = &define.Bucket{Timestamp: ts}
+			out = append(out, last)
+		}
+		last.Last = v.Price
+		last.Count++
+		if v.Stock == define.StockOffShelf {
+			continue
+		}
+		if last.MinTimestamp == 0 || v.Price < last.Min {
+			last.Min, last.MinTimestamp = v.Price, v.Timestamp
+		}
+		if last.MaxTimestamp == 0 || v.Price > last.Max {
+			last.Max, last.MaxTimestamp = v.Price, v.Timestamp
+		}
+	}
+	return out, nil
+}
+
+// Query 查询历史，bucket 为空时返回原始采样
+func Query(sku, begin, end int64, bucket string) (*define.History, error) {
+	samples, err := Select(sku, begin, end)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &define.History{
+		SkuID:  sku,
+		Begin:  begin,
+		End:    end,
+		Bucket: bucket,
+	}
+
+	for _, v := range samples {
+		if v.Stock == define.StockOffShelf {
+			continue
+		}
+		if h.MinTimestamp == 0 || v.Price < h.Min {
+			h.Min, h.MinTimestamp = v.Price, v.Timestamp
+		}
+		if h.MaxTimestamp == 0 || v.Price > h.Max {
+			h.Max, h.MaxTimestamp = v.Price, v.Timestamp
+		}
+	}
+
+	if bucket == "" {
+		h.Samples = samples
+		return h, nil
+	}
+
+	if h.Buckets, err = Downsample(samples, bucket); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
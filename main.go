@@ -1,77 +1,229 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"flag"
 	"fmt"
+	"html"
+	"html/template"
 	"log"
 	"math/rand"
 	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
 	"sync"
-	"text/template"
+	"syscall"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/panshiqu/shopping/alert"
+	"github.com/panshiqu/shopping/auth"
 	"github.com/panshiqu/shopping/cache"
-	"github.com/panshiqu/shopping/db"
+	"github.com/panshiqu/shopping/cart"
+	"github.com/panshiqu/shopping/chart"
+	"github.com/panshiqu/shopping/config"
 	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/history"
+	"github.com/panshiqu/shopping/notify"
+	"github.com/panshiqu/shopping/outbox"
+	"github.com/panshiqu/shopping/pricing"
+	"github.com/panshiqu/shopping/protect"
+	"github.com/panshiqu/shopping/region"
 	"github.com/panshiqu/shopping/spider"
+	"github.com/panshiqu/shopping/store"
+	_ "modernc.org/sqlite"
 )
 
 var aliasMutex sync.Mutex
 
+var captchaMutex sync.Mutex
+
 var captcha map[string]int32
 
-var index = template.Must(template.New("index").Parse(`<html><body><ul><li>只是来玩游戏的请点击 <a href='http://www.iplaygame.com.cn:8081' target='_blank'>这里</a></li><li>请搜索 <font color="red">Min</font> 快速浏览当前价格为最低价的商品</li><li>请搜索 <font color="red">京东秒杀</font> 快速浏览正在参与或即将参与秒杀的商品</li></ul>{{range $k, $v := .Proms}}{{$k}} {{$v}}<br />{{end}}<table>
-	{{range .Args}} <tr><td colspan="2"><hr />{{if .IsMinPrice}}<font color="red" size="4">Min</font> {{end}}编号：{{.SkuID}} 价格：<font color="red" size="4">{{.Price}}</font> 刷新时间：{{.Timestamp}} 最低价：{{.MinPrice}} 最高价：{{.MaxPrice}} 已持续：{{.Duration}} 有效采样{{.Sampling}}次 {{if eq $.Alias ""}}<a href='{{printf "/subscribe?sku=%d&keywords=%s" .SkuID .Name}}' target='_blank'>订阅</a>{{else}}<a href='{{printf "/unsubscribe?sku=%d&alias=%s" .SkuID $.Alias}}' target='_blank'>退订</a>{{end}}</td></tr>{{.Content}} {{end}}
+// checkCaptcha 校验验证码，无论是否通过均作废，需重新获取
+func checkCaptcha(id, in string) bool {
+	captchaMutex.Lock()
+	defer captchaMutex.Unlock()
+	v, ok := captcha[id]
+	delete(captcha, id)
+	return ok && in == strconv.Itoa(int(v))
+}
+
+var index = template.Must(template.New("index").Funcs(template.FuncMap{"ko": func(in int64) string {
+	return time.Unix(in/1000, 0).Format("01-02 15:04")
+}, "game": func() string {
+	return config.Ins.GameURL
+}, "promotion": func(in *define.Promotion) template.HTML {
+	text := html.EscapeString(in.Text)
+	if u, err := url.Parse(in.URL); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return template.HTML("<a href='" + html.EscapeString(in.URL) + "' target='_blank'>" + text + "</a>")
+	}
+	return template.HTML(text)
+}}).Parse(`<html><body><ul><li>只是来玩游戏的请点击 <a href='{{game}}' target='_blank'>这里</a></li><li>请搜索 <font color="red">Min</font> 快速浏览当前价格为最低价的商品</li><li>请搜索 <font color="red">京东秒杀</font> 快速浏览正在参与或即将参与秒杀的商品</li>{{if .Token}}<li><a href='/cart?share={{.Token}}' target='_blank'>凑单规划</a></li>{{else if .Alias}}<li><a href='/cart?alias={{.Alias}}' target='_blank'>凑单规划</a></li>{{end}}{{if .Login}}<li>{{.Login}}：<a href='/?alias={{.Login}}'>我的订阅</a> <a href='/subscribe' target='_blank'>订阅商品</a> <a href='/channel' target='_blank'>通知渠道</a> <a href='/notifications' target='_blank'>通知记录</a> <a href='/purchase' target='_blank'>价保</a> <form method="post" action="/share" style="display:inline"><input type="hidden" name="csrf" value="{{.CSRF}}"><input type="hidden" name="share" value="1"><input type="submit" value="{{if .Share}}重新生成专属链接{{else}}开启专属链接分享（他人只读）{{end}}"></form>{{if .Share}} <form method="post" action="/share" style="display:inline"><input type="hidden" name="csrf" value="{{.CSRF}}"><input type="hidden" name="share" value="0"><input type="submit" value="关闭专属链接分享"></form>{{end}} <form method="post" action="/logout" style="display:inline"><input type="hidden" name="csrf" value="{{.CSRF}}"><input type="submit" value="退出"></form></li>{{else}}<li><a href='/login'>登录</a> or <a href='/bind' target='_blank'>绑定</a></li>{{end}}</ul>{{range $k, $v := .Proms}}{{$k}} {{$v}}<br />{{end}}<table>
+	{{range .Args}} <tr><td colspan="2"><hr />{{if .IsMinPrice}}<font color="red" size="4">Min</font> {{end}}{{if .IsOffShelf}}<font color="gray" size="4">已下柜</font> {{end}}编号：{{.SkuID}} 价格：<font color="red" size="4">{{.Price}}</font> 刷新时间：{{.Timestamp}} 最低价：{{.MinPrice}} 最高价：{{.MaxPrice}} 已持续：{{.Duration}} 有效采样{{.Sampling}}次 <a href='{{printf "/history?sku=%d" .SkuID}}' target='_blank'>历史</a> {{if eq $.Alias ""}}<a href='/subscribe?sku={{.SkuID}}&keywords={{.Name}}' target='_blank'>订阅</a>{{else if eq $.Alias $.Login}}<form method="post" action="/unsubscribe" style="display:inline"><input type="hidden" name="sku" value="{{.SkuID}}"><input type="hidden" name="csrf" value="{{$.CSRF}}"><input type="submit" value="退订"></form>{{end}}</td></tr><tr><td><a href='{{.URL}}' target='_blank'><img src='{{.Src}}' /></a></td><td>{{if .KoBeginTime}}<font color='red'>【京东秒杀{{ko .KoBeginTime}}开始】</font>{{end}}{{if .KoEndTime}}<font color='red'>【京东秒杀{{ko .KoEndTime}}结束】</font>{{end}}<a href='{{.URL}}' target='_blank'>{{.Name}}</a>{{if .Breakdown}}<br /><font color="gray">价格构成：{{.Breakdown}}</font>{{with .Breakdown.Compare}}<br /><font color="gray">对比：{{.}}</font>{{end}}{{end}}{{range .Promotions}}<br />{{promotion .}}{{end}}</td></tr><tr><td colspan="2"><a href='{{printf "/history?sku=%d&bucket=day" .SkuID}}' target='_blank'><img src='{{printf "/chart?sku=%d" .SkuID}}' loading="lazy" /></a></td></tr> {{end}}
 	</table></body></html>`))
 
-func procRequest(w http.ResponseWriter, r *http.Request) {
-	var err error
-	var rows *sql.Rows
-	alias := r.FormValue("alias")
+var notificationsPage = template.Must(template.New("notifications").Funcs(template.FuncMap{"date": func(in int64) string {
+	if in == 0 {
+		return ""
+	}
+	return time.Unix(in, 0).Format("2006-01-02 15:04:05")
+}, "status": func(in int64) string {
+	switch in {
+	case define.NotifyPending:
+		return "待投递"
+	case define.NotifyDelivered:
+		return "已通知"
+	}
+	return "失败"
+}}).Parse(`<html><body><table border="1"><tr><th>编号</th><th>渠道</th><th>内容</th><th>状态</th><th>产生时间</th><th>通知时间</th><th>尝试次数</th><th>失败原因</th><th>下次尝试</th></tr>
+	{{range .}}<tr><td>{{.SkuID}}</td><td>{{.Type}}</td><td>{{.Message}}</td><td>{{status .Status}}</td><td>{{date .CreateTime}}</td><td>{{date .DeliverTime}}</td><td>{{.Attempts}}</td><td>{{.LastError}}</td><td>{{if eq .Status 0}}{{date .NextAttempt}}{{end}}</td></tr>{{end}}
+	</table></body></html>`))
 
-	if alias == "" {
-		rows, err = db.Ins.Query("SELECT sku FROM sku ORDER BY priority")
-	} else {
-		var id string
+var purchasePage = template.Must(template.New("purchase").Funcs(template.FuncMap{"date": func(in int64) string {
+	return time.Unix(in, 0).Format("2006-01-02")
+}}).Parse(`<html><body><form method="post">
+	<input type="hidden" name="csrf" value="{{.CSRF}}">
+	<input type="number" name="sku">*商品编号<br />
+	<input type="number" name="price" step="0.01">*购买价<br />
+	<input type="number" name="quantity" value="1" min="1">*数量<br />
+	<input type="date" name="date">*购买日期<br />
+	<input type="number" name="days" value="{{.Days}}" min="1">*价保天数<br /><br />
+	<input type="submit" value="记录购买">
+	</form><table border="1"><tr><th>编号</th><th>购买价</th><th>数量</th><th>购买日期</th><th>价保天数</th><th>已提醒价格</th><th></th></tr>
+	{{range .Purchases}}<tr><td>{{.SkuID}}</td><td>{{.Price}}</td><td>{{.Quantity}}</td><td>{{date .PurchaseTime}}</td><td>{{.Days}}</td><td>{{.NotifiedPrice}}</td><td><form method="post"><input type="hidden" name="csrf" value="{{$.CSRF}}"><input type="hidden" name="delete" value="{{.ID}}"><input type="submit" value="删除"></form></td></tr>{{end}}
+	</table></body></html>`))
 
-		if err := db.Ins.QueryRow("SELECT id FROM user WHERE alias = ?", alias).Scan(&id); err != nil {
-			log.Println("procRequest QueryRow", err)
-			fmt.Fprint(w, err)
+var historyPage = template.Must(template.New("history").Funcs(template.FuncMap{"date": func(in int64) string {
+	return time.Unix(in, 0).Format("2006-01-02 15:04:05")
+}}).Parse(`<html><body>编号：{{.SkuID}} 最低价：<font color="red">{{.Min}}</font>（{{date .MinTimestamp}}） 最高价：{{.Max}}（{{date .MaxTimestamp}}）<br />
+	<a href='{{printf "/history?sku=%d" .SkuID}}'>全部</a> <a href='{{printf "/history?sku=%d&bucket=hour" .SkuID}}'>按小时</a> <a href='{{printf "/history?sku=%d&bucket=day" .SkuID}}'>按天</a><table border="1">
+	{{if .Bucket}}<tr><th>时间</th><th>最低价</th><th>最高价</th><th>最后价格</th><th>采样</th></tr>{{range .Buckets}}<tr><td>{{date .Timestamp}}</td><td>{{.Min}}（{{date .MinTimestamp}}）</td><td>{{.Max}}（{{date .MaxTimestamp}}）</td><td>{{.Last}}</td><td>{{.Count}}</td></tr>{{end}}
+	{{else}}<tr><th>时间</th><th>价格</th><th>价格构成</th></tr>{{range .Samples}}<tr><td>{{date .Timestamp}}</td><td>{{.Price}}</td><td>{{if .Breakdown}}{{.Breakdown}}{{end}}</td></tr>{{end}}{{end}}
+	</table></body></html>`))
+
+var cartPage = template.Must(template.New("cart").Parse(`<html><body><form>{{if .Token}}<input type="hidden" name="share" value="{{.Token}}">{{else if .Alias}}<input type="hidden" name="alias" value="{{.Alias}}">{{else}}{{range .Args}}<input type="hidden" name="sku" value="{{.SkuID}}">{{end}}{{end}}<table border="1"><tr><th>编号</th><th>名称</th><th>价格</th><th>数量</th></tr>
+	{{range .Args}}<tr><td>{{.SkuID}}</td><td>{{.Name}}</td><td>{{.Price}}</td><td><input type="number" name="q{{.SkuID}}" value="{{index $.Quantity .SkuID}}" min="1" max="{{$.Max}}"></td></tr>{{end}}
+	</table><input type="submit" value="规划凑单"></form>
+	原价合计：{{.Plan.ListTotal}} 到手合计：<font color="red">{{.Plan.Total}}</font> 节省：{{.Plan.Saving}}{{if .Plan.Unavailable}} 已下柜：{{range .Plan.Unavailable}}{{.}} {{end}}{{end}}<table border="1"><tr><th>促销</th><th>商品</th><th>小计</th><th>优惠</th><th>合计</th></tr>
+	{{range .Plan.Groups}}<tr><td>{{if .Promotion}}{{.Promotion}}{{else}}不参与促销{{end}}</td><td>{{range .Lines}}{{.SkuID}} {{.Name}} {{.Price}}×{{.Quantity}}<br />{{end}}</td><td>{{.Subtotal}}</td><td>{{.Reduction}}</td><td>{{.Total}}</td></tr>{{end}}
+	</table></body></html>`))
+
+func parseHistoryArgs(r *http.Request) (sku, begin, end int64, bucket string, err error) {
+	if sku, err = strconv.ParseInt(r.FormValue("sku"), 10, 64); err != nil {
+		return
+	}
+	if !cache.Exist(sku) {
+		err = define.ErrNotExist
+		return
+	}
+	if v := r.FormValue("begin"); v != "" {
+		if begin, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return
+		}
+	}
+	end = time.Now().Unix() + 1
+	if v := r.FormValue("end"); v != "" {
+		if end, err = strconv.ParseInt(v, 10, 64); err != nil {
 			return
 		}
-
-		rows, err = db.Ins.Query("SELECT sku FROM subscribe WHERE id = ? ORDER BY keywords", id)
 	}
+	bucket = r.FormValue("bucket")
+	return
+}
 
+// parseCart 凑单商品为用户的订阅或 sku 指定的商品，默认各买一件，数量通过 q<编号> 指定
+func parseCart(r *http.Request) (*define.IndexData, map[int64]int64, *define.CartPlan, error) {
+	alias, u, _, err := viewAlias(r)
 	if err != nil {
-		log.Println("procRequest Query", err)
-		fmt.Fprint(w, err)
-		return
+		return nil, nil, nil, err
+	}
+
+	if alias == "" && u != nil {
+		alias = u.Alias
 	}
 
-	defer rows.Close()
+	var data *define.IndexData
+	switch {
+	case alias != "":
+		if data, err = selectIndex(alias); err != nil {
+			return nil, nil, nil, err
+		}
+		data.Token = r.FormValue("share")
+	case len(r.Form["sku"]) != 0:
+		var ids []int64
+		seen := make(map[int64]bool)
+		for _, v := range r.Form["sku"] {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		data = indexData(ids, "")
+	default:
+		return nil, nil, nil, define.ErrEmptyCart
+	}
+
+	if len(data.Args) > cart.MaxLines {
+		return nil, nil, nil, define.ErrIllegalPurchase
+	}
+
+	quantity := make(map[int64]int64)
+	for _, v := range data.Args {
+		quantity[v.SkuID] = 1
+		if q := r.FormValue(fmt.Sprintf("q%d", v.SkuID)); q != "" {
+			if quantity[v.SkuID], err = strconv.ParseInt(q, 10, 64); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		if quantity[v.SkuID] <= 0 || quantity[v.SkuID] > pricing.MaxQuantity {
+			return nil, nil, nil, define.ErrIllegalPurchase
+		}
+	}
+
+	return data, quantity, cart.Plan(data.Args, quantity), nil
+}
 
+func selectIndex(alias string) (*define.IndexData, error) {
 	var ids []int64
-	for rows.Next() {
-		var sku int64
 
-		if err := rows.Scan(&sku); err != nil {
-			log.Println("procRequest Scan", err)
-			fmt.Fprint(w, err)
-			return
+	if alias == "" {
+		skus, err := store.Ins.SKUs()
+		if err != nil {
+			return nil, err
 		}
 
-		ids = append(ids, sku)
-	}
+		for _, v := range skus {
+			ids = append(ids, v.SkuID)
+		}
+	} else {
+		u, err := store.Ins.UserByAlias(alias)
+		if err != nil {
+			return nil, err
+		}
 
-	if err := rows.Err(); err != nil {
-		log.Println("procRequest Err", err)
-		fmt.Fprint(w, err)
-		return
+		subs, err := store.Ins.SubscriptionsOf(u.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range subs {
+			ids = append(ids, v.SkuID)
+		}
 	}
 
+	return indexData(ids, alias), nil
+}
+
+func indexData(ids []int64, alias string) *define.IndexData {
 	data := &define.IndexData{
 		Args:  cache.Select(ids),
 		Alias: alias,
@@ -79,13 +231,35 @@ func procRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	for _, v := range data.Args {
-		begin := strings.Index(v.Content, "<!--begin-->") + 12
-		end := strings.Index(v.Content, "<!--end-->")
-		for _, vv := range strings.Split(v.Content[begin:end], "<br />") {
-			data.Proms[vv]++
+		for _, vv := range v.Promotions {
+			data.Proms[vv.Text]++
 		}
 	}
 
+	return data
+}
+
+func procRequest(w http.ResponseWriter, r *http.Request) {
+	alias, u, s, err := viewAlias(r)
+	if err != nil {
+		log.Println("procRequest viewAlias", err)
+		fmt.Fprint(w, err)
+		return
+	}
+
+	data, err := selectIndex(alias)
+	if err != nil {
+		log.Println("procRequest selectIndex", err)
+		fmt.Fprint(w, err)
+		return
+	}
+
+	data.Token = r.FormValue("share")
+
+	if u != nil {
+		data.Login, data.Share, data.CSRF = u.Alias, u.Share != "", s.CSRF
+	}
+
 	if err := index.Execute(w, data); err != nil {
 		log.Println("procRequest Execute", err)
 		fmt.Fprint(w, err)
@@ -93,38 +267,132 @@ func procRequest(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func procBindRequest(w http.ResponseWriter, r *http.Request) {
-	id := r.FormValue("id")
+func procHistoryRequest(w http.ResponseWriter, r *http.Request) {
+	sku, begin, end, bucket, err := parseHistoryArgs(r)
+	if err != nil {
+		log.Println("procHistoryRequest parseHistoryArgs", err)
+		fmt.Fprint(w, err)
+		return
+	}
+
+	h, err := history.Query(sku, begin, end, bucket)
+	if err != nil {
+		log.Println("procHistoryRequest Query", err)
+		fmt.Fprint(w, err)
+		return
+	}
 
-	if r.FormValue("admin") == "" && (id == "" || captcha[id] == 0 || fmt.Sprintf("%d", captcha[id]) != r.FormValue("captcha")) {
+	if err := historyPage.Execute(w, h); err != nil {
+		log.Println("procHistoryRequest Execute", err)
+		fmt.Fprint(w, err)
+		return
+	}
+}
+
+func procChartRequest(w http.ResponseWriter, r *http.Request) {
+	sku, begin, end, _, err := parseHistoryArgs(r)
+	if err != nil {
+		log.Println("procChartRequest parseHistoryArgs", err)
+		http.Error(w, err.Error(), statusCode(err))
+		return
+	}
+
+	h, err := history.Query(sku, begin, end, "")
+	if err != nil {
+		log.Println("procChartRequest Query", err)
+		http.Error(w, err.Error(), statusCode(err))
+		return
+	}
+
+	windows, err := history.Windows(sku, begin, end)
+	if err != nil {
+		log.Println("procChartRequest Windows", err)
+		http.Error(w, err.Error(), statusCode(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+
+	if err := chart.SVG(w, h, windows); err != nil {
+		log.Println("procChartRequest SVG", err)
+		return
+	}
+}
+
+func procCartRequest(w http.ResponseWriter, r *http.Request) {
+	data, quantity, plan, err := parseCart(r)
+	if err != nil {
+		log.Println("procCartRequest parseCart", err)
+		fmt.Fprint(w, err)
+		return
+	}
+
+	if err := cartPage.Execute(w, map[string]interface{}{
+		"Alias":    data.Alias,
+		"Args":     data.Args,
+		"Quantity": quantity,
+		"Plan":     plan,
+		"Max":      pricing.MaxQuantity,
+	}); err != nil {
+		log.Println("procCartRequest Execute", err)
+		fmt.Fprint(w, err)
+		return
+	}
+}
+
+func procBindRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
 		fmt.Fprint(w, `
 			<html>
 			<body>
-			<form>
+			<form method="post">
 			<input type="text" name="id" size="32">*休闲益智游戏公众号发送 id 获得<br />
 			<input type="text" name="alias">*请设置别名，暂仅支持纯字母组合，不区分大小写<br />
-			<input type="text" name="password">*请设置密码，暂仅支持6位纯数字组合<br />
+			<input type="password" name="password">*请设置密码，`+strconv.Itoa(auth.MinPassword)+`到`+strconv.Itoa(auth.MaxPassword)+`个字符<br />
+			<input type="text" name="area">配送区域，形如 省_市_区_街道 的京东地区编号，默认 `+region.Default+`<br />
 			<input type="number" name="captcha"> <a href="/captcha" target="_blank">获取</a><br /><br />
 			<input type="submit" value="绑定">
 			</form>
-			<img src="http://www.iplaygame.com.cn:8081/qrcode.jpg" alt="休闲益智游戏">
+			<img src="`+config.Ins.GameURL+`/qrcode.jpg" alt="休闲益智游戏">
 			</body>
 			</html>
 			`)
 		return
 	}
 
-	alias := strings.ToLower(r.FormValue("alias"))
-	password := r.FormValue("password")
+	id := r.PostFormValue("id")
+	alias := strings.ToLower(r.PostFormValue("alias"))
+	password := r.PostFormValue("password")
+	area := r.PostFormValue("area")
+	admin := r.PostFormValue("admin") != ""
+
+	log.Println("procBindRequest", id, alias, area, admin)
+
+	// 管理员代为绑定需管理员会话或表单中的管理密码
+	if admin && !bindAdmin(r) {
+		log.Println("procBindRequest", define.ErrUnauthorized)
+		fmt.Fprint(w, define.ErrUnauthorized)
+		return
+	}
 
-	log.Println("procBindRequest", id, alias, password)
+	if id == "" {
+		log.Println("procBindRequest", define.ErrIllegalLen)
+		fmt.Fprint(w, define.ErrIllegalLen)
+		return
+	}
 
-	if l := len(alias); l == 0 || l > 128 || len(password) != 6 {
+	if l := len(alias); l == 0 || l > 128 {
 		log.Println("procBindRequest", define.ErrIllegalLen)
 		fmt.Fprint(w, define.ErrIllegalLen)
 		return
 	}
 
+	if l := len(password); l < auth.MinPassword || l > auth.MaxPassword {
+		log.Println("procBindRequest", define.ErrIllegalPassword)
+		fmt.Fprint(w, define.ErrIllegalPassword)
+		return
+	}
+
 	for _, v := range alias {
 		if v < 'a' || v > 'z' {
 			log.Println("procBindRequest", define.ErrIllegalAlias)
@@ -133,64 +401,88 @@ func procBindRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	for _, v := range password {
-		if v < '0' || v > '9' {
-			log.Println("procBindRequest", define.ErrIllegalPassword)
-			fmt.Fprint(w, define.ErrIllegalPassword)
-			return
-		}
+	if err := region.Check(area); err != nil {
+		log.Println("procBindRequest Check", err)
+		fmt.Fprint(w, err)
+		return
+	}
+
+	if !admin && !checkCaptcha(id, r.PostFormValue("captcha")) {
+		log.Println("procBindRequest", define.ErrIllegalCaptcha)
+		fmt.Fprint(w, define.ErrIllegalCaptcha)
+		return
 	}
 
 	aliasMutex.Lock()
 	defer aliasMutex.Unlock()
 
-	if err := db.Ins.QueryRow("SELECT alias FROM user WHERE alias = ? AND id <> ?", alias, id).Scan(&alias); err != sql.ErrNoRows {
-		if err == nil {
-			err = define.ErrAlreadyExist
-		}
-		log.Println("procBindRequest QueryRow", err)
+	if err := auth.Bind(&define.User{ID: id, Alias: alias, Password: password, Area: area}); err != nil {
+		log.Println("procBindRequest Bind", err)
 		fmt.Fprint(w, err)
 		return
 	}
 
-	if _, err := db.Ins.Exec("INSERT INTO user (id,alias,password) VALUES (?,?,?) ON DUPLICATE KEY UPDATE alias = ?,password = ?", id, alias, password, alias, password); err != nil {
-		log.Println("procBindRequest Exec", err)
+	// 重新绑定可能修改了密码，注销已有会话
+	if err := auth.Revoke(id); err != nil {
+		log.Println("procBindRequest Revoke", err)
 		fmt.Fprint(w, err)
 		return
 	}
 
-	if r.FormValue("admin") == "" {
-		fmt.Fprintf(w, "<html><body>绑定成功，请自主<a href='/subscribe' target='_blank'>订阅商品</a> or <a href='/' target='_blank'>首页快速订阅</a>，然后访问您的<a href='/?alias=%s' target='_blank'>专属链接</a></body></html>", alias)
+	if !admin {
+		if _, err := auth.Create(w, r, id); err != nil {
+			log.Println("procBindRequest Create", err)
+			fmt.Fprint(w, err)
+			return
+		}
+
+		fmt.Fprintf(w, "<html><body>绑定成功，已登录，请自主<a href='/subscribe' target='_blank'>订阅商品</a> or <a href='/' target='_blank'>首页快速订阅</a>，然后访问您的<a href='/?alias=%s' target='_blank'>专属链接</a></body></html>", alias)
 	} else {
 		fmt.Fprintf(w, `绑定成功
-首页：http://www.iplaygame.com.cn:8080/
-添加商品：http://www.iplaygame.com.cn:8080/admin
-订阅商品：http://www.iplaygame.com.cn:8080/subscribe
-专属链接：http://www.iplaygame.com.cn:8080/?alias=%s`, alias)
+首页：%[1]s/
+添加商品：%[1]s/admin
+订阅商品：%[1]s/subscribe
+专属链接：%[1]s/?alias=%[2]s`, config.Ins.PublicURL, alias)
 	}
 }
 
 func procAdminRequest(w http.ResponseWriter, r *http.Request) {
-	if r.FormValue("password") != "161015" {
-		fmt.Fprint(w, `
+	if !adminPassword(r, "password") {
+		var options string
+		for _, v := range spider.Names() {
+			options += fmt.Sprintf(`<option value="%s">%s</option>`, v, v)
+		}
+		fmt.Fprintf(w, `
 			<html>
 			<body>
-			<form>
+			<form method="post">
+			<select name="retailer">%s</select>*零售商<br />
 			<input type="number" name="sku">*商品编号（https://item.jd.com/商品编号.html）<br />
-			<input type="number" name="priority" value="28800" min="28800">*优先级（作为刷新周期，越小越频繁，以秒为单位，最低28800秒）<br />
+			<input type="number" name="priority" value="%[2]d" min="%[2]d">*优先级（作为刷新周期，越小越频繁，以秒为单位，最低%[2]d秒）<br />
 			<input type="password" name="password">*请输入密码，不能谁都能添加吧<br /><br />
 			<input type="submit" value="Submit">
 			</form>
 			</body>
 			</html>
-			`)
+			`, options, config.Ins.MinPriority)
 		return
 	}
 
 	skuStr := r.FormValue("sku")
 	priorityStr := r.FormValue("priority")
+	retailer := r.FormValue("retailer")
 
-	log.Println("procAdminRequest", skuStr, priorityStr)
+	if retailer == "" {
+		retailer = "jd"
+	}
+
+	log.Println("procAdminRequest", retailer, skuStr, priorityStr)
+
+	if _, err := spider.Lookup(retailer); err != nil {
+		log.Println("procAdminRequest Lookup", err)
+		fmt.Fprint(w, err)
+		return
+	}
 
 	sku, err := strconv.Atoi(skuStr)
 	if err != nil {
@@ -212,19 +504,19 @@ func procAdminRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if priority < 8*60*60 {
+	if int64(priority) < config.Ins.MinPriority {
 		log.Println("procAdminRequest", define.ErrToSmallPriority)
 		fmt.Fprint(w, define.ErrToSmallPriority)
 		return
 	}
 
-	if _, err := db.Ins.Exec("INSERT INTO sku (sku,priority) VALUES (?,?)", sku, priority); err != nil {
-		log.Println("procAdminRequest Exec", err)
+	if err := store.Ins.AddSKU(&define.SKU{SkuID: int64(sku), Priority: int64(priority), Retailer: retailer}); err != nil {
+		log.Println("procAdminRequest AddSKU", err)
 		fmt.Fprint(w, err)
 		return
 	}
 
-	if err := spider.Add(int64(sku), int64(priority)); err != nil {
+	if err := spider.Add(r.Context(), retailer, int64(sku), int64(priority)); err != nil {
 		log.Println("procAdminRequest Add", err)
 		fmt.Fprint(w, err)
 		return
@@ -250,52 +542,69 @@ func procCaptchaRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	captcha[id] = rand.Int31n(900000) + 100000
+	code := rand.Int31n(900000) + 100000
 
-	resp, err := http.Get(fmt.Sprintf(`http://localhost/push?id=%s&message=验证码：%d`, id, captcha[id]))
+	captchaMutex.Lock()
+	captcha[id] = code
+	captchaMutex.Unlock()
+
+	n, err := notify.Lookup("wechat")
 	if err != nil {
-		log.Println("procCaptchaRequest Get", err)
+		log.Println("procCaptchaRequest Lookup", err)
 		fmt.Fprint(w, err)
 		return
 	}
 
-	resp.Body.Close()
+	if err := n.Notify(id, fmt.Sprintf("验证码：%d", code)); err != nil {
+		log.Println("procCaptchaRequest Notify", err)
+		fmt.Fprint(w, err)
+		return
+	}
 
 	fmt.Fprint(w, "已发送，请打开休闲益智游戏公众号查看\n若未收到，可能因为您好久未与公众号交互，请在公众号内发送任意内容之后再次获取验证码")
 }
 
 func procSubscribeRequest(w http.ResponseWriter, r *http.Request) {
+	u, sess, ok := requireLogin(w, r, "procSubscribeRequest")
+	if !ok {
+		return
+	}
+
 	skuStr := r.FormValue("sku")
-	alias := r.FormValue("alias")
-	password := r.FormValue("password")
 	keywords := r.FormValue("keywords")
 
-	if skuStr == "" || alias == "" {
+	if r.Method != http.MethodPost || skuStr == "" {
 		fmt.Fprintf(w, `
 			<html>
 			<body>
-			<form>
+			<form method="post">
+			<input type="hidden" name="csrf" value="%s">
 			<input type="number" name="sku" value="%s">*请订阅添加过的商品编号，<a href='/admin' target='_blank'>添加商品</a><br />
-			<input type="text" name="alias">*绑定时输入的别名<br />
-			<input type="text" name="password">*绑定时输入的密码<br />
-			<input type="text" name="keywords" value="%s" size="64">*关键字用于排序<br /><br />
+			<input type="text" name="keywords" value="%s" size="64">*关键字用于排序<br />
+			<select name="rule">
+			<option value="%d">历史最低价</option>
+			<option value="%d">目标价</option>
+			<option value="%d">较订阅时降幅</option>
+			<option value="%d">低于N日均价</option>
+			<option value="%d">到货提醒</option>
+			<option value="%d">下柜提醒</option>
+			</select>*提醒规则<br />
+			<input type="number" name="target" step="0.01">目标价或降幅百分比<br />
+			<input type="number" name="days">N日均价的天数<br />
+			<input type="text" name="area">配送区域，为空时使用绑定时的设置<br />
+			<input type="checkbox" name="prom" value="%d">新优惠券 <input type="checkbox" name="prom" value="%d">新满减 <input type="checkbox" name="prom" value="%d">新赠品 <input type="checkbox" name="prom" value="%d">其它促销（促销变化提醒）<br /><br />
 			<input type="submit" value="订阅">
 			</form>
 			</body>
 			</html>
-			`, skuStr, keywords)
+			`, sess.CSRF, html.EscapeString(skuStr), html.EscapeString(keywords), define.RuleMinPrice, define.RuleTargetPrice, define.RulePercentDrop, define.RuleAverage, define.RuleRestock, define.RuleDelist,
+			define.PromAlertCoupon, define.PromAlertReduction, define.PromAlertGift, define.PromAlertOther)
 		return
 	}
 
-	log.Println("procSubscribeRequest", skuStr, alias, password, keywords)
+	log.Println("procSubscribeRequest", skuStr, u.Alias, keywords, r.FormValue("rule"), r.FormValue("target"), r.FormValue("days"), r.Form["prom"], r.FormValue("area"))
 
-	var id string
-
-	if err := db.Ins.QueryRow("SELECT id FROM user WHERE alias = ? AND password = ?", alias, password).Scan(&id); err != nil {
-		log.Println("procSubscribeRequest QueryRow", err)
-		fmt.Fprint(w, err)
-		return
-	}
+	id := u.ID
 
 	sku, err := strconv.Atoi(skuStr)
 	if err != nil {
@@ -310,68 +619,406 @@ func procSubscribeRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := db.Ins.Exec("INSERT INTO subscribe (id,sku,keywords) VALUES (?,?,?) ON DUPLICATE KEY UPDATE keywords = ?", id, sku, keywords, keywords); err != nil {
-		log.Println("procSubscribeRequest Exec", err)
+	s, err := parseSubscription(r, int64(sku))
+	if err != nil {
+		log.Println("procSubscribeRequest parseSubscription", err)
 		fmt.Fprint(w, err)
 		return
 	}
 
-	fmt.Fprintf(w, "<html><body>订阅成功，<a href='/' target='_blank'>首页快速订阅</a> or <a href='/subscribe' target='_blank'>继续订阅</a> or <a href='/?alias=%s' target='_blank'>专属链接</a></body></html>", alias)
+	s.Keywords = keywords
+
+	if err := store.Ins.Subscribe(id, s); err != nil {
+		log.Println("procSubscribeRequest Subscribe", err)
+		fmt.Fprint(w, err)
+		return
+	}
+
+	fmt.Fprintf(w, "<html><body>订阅成功，<a href='/' target='_blank'>首页快速订阅</a> or <a href='/subscribe' target='_blank'>继续订阅</a> or <a href='/?alias=%s' target='_blank'>专属链接</a></body></html>", u.Alias)
+}
+
+func parseSubscription(r *http.Request, sku int64) (*define.Subscription, error) {
+	var err error
+
+	s := &define.Subscription{SkuID: sku}
+
+	if v := r.FormValue("rule"); v != "" {
+		if s.Rule, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	if v := r.FormValue("target"); v != "" {
+		if s.Target, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	if v := r.FormValue("days"); v != "" {
+		if s.Days, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, v := range r.Form["prom"] {
+		prom, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		s.PromAlert |= prom
+	}
+
+	if err := alert.Check(s); err != nil {
+		return nil, err
+	}
+
+	s.Area = r.FormValue("area")
+	if err := region.Check(s.Area); err != nil {
+		return nil, err
+	}
+
+	if args := cache.Select([]int64{sku}); len(args) != 0 {
+		s.BasePrice = args[0].Price
+	}
+
+	return s, nil
 }
 
 func procUnSubscribeRequest(w http.ResponseWriter, r *http.Request) {
+	u, sess, ok := requireLogin(w, r, "procUnSubscribeRequest")
+	if !ok {
+		return
+	}
+
 	sku := r.FormValue("sku")
-	alias := r.FormValue("alias")
-	password := r.FormValue("password")
 
-	if password == "" {
+	if r.Method != http.MethodPost || sku == "" {
 		fmt.Fprintf(w, `
 			<html>
 			<body>
-			<form>
-			<input type="number" name="sku" value="%s">*请退订订阅过的商品编号，<a href='/subscribe' target='_blank'>订阅商品</a><br />
-			<input type="text" name="alias" value="%s">*绑定时输入的别名<br />
-			<input type="text" name="password">*绑定时输入的密码<br /><br />
+			<form method="post">
+			<input type="hidden" name="csrf" value="%s">
+			<input type="number" name="sku" value="%s">*请退订订阅过的商品编号，<a href='/subscribe' target='_blank'>订阅商品</a><br /><br />
 			<input type="submit" value="退订">
 			</form>
 			</body>
 			</html>
-			`, sku, alias)
+			`, sess.CSRF, html.EscapeString(sku))
+		return
+	}
+
+	log.Println("procUnSubscribeRequest", sku, u.Alias)
+
+	skuID, err := strconv.ParseInt(sku, 10, 64)
+	if err != nil {
+		log.Println("procUnSubscribeRequest ParseInt", err)
+		fmt.Fprint(w, err)
+		return
+	}
+
+	if err := store.Ins.Unsubscribe(u.ID, skuID); err != nil {
+		log.Println("procUnSubscribeRequest Unsubscribe", err)
+		fmt.Fprint(w, err)
+		return
+	}
+
+	fmt.Fprintf(w, "<html><body>退订成功，<a href='/unsubscribe' target='_blank'>继续退订</a> or <a href='/?alias=%s' target='_blank'>专属链接快速退订</a></body></html>", u.Alias)
+}
+
+func procChannelRequest(w http.ResponseWriter, r *http.Request) {
+	u, sess, ok := requireLogin(w, r, "procChannelRequest")
+	if !ok {
+		return
+	}
+
+	typ := r.FormValue("type")
+	target := strings.TrimSpace(r.FormValue("target"))
+
+	if r.Method != http.MethodPost || typ == "" {
+		var options string
+		for _, v := range notify.Names() {
+			options += fmt.Sprintf(`<option value="%s">%s</option>`, v, v)
+		}
+		fmt.Fprintf(w, `
+			<html>
+			<body>
+			<form method="post">
+			<input type="hidden" name="csrf" value="%s">
+			<select name="type">%s</select>*通知渠道<br />
+			<input type="text" name="target" size="64">接收地址（wechat 留空即可，email 填邮箱，telegram 填 chat_id，webhook、dingtalk、feishu 填 Webhook 地址），为空时删除该渠道<br /><br />
+			<input type="submit" value="设置">
+			</form>
+			</body>
+			</html>
+			`, sess.CSRF, options)
+		return
+	}
+
+	log.Println("procChannelRequest", u.Alias, typ, target)
+
+	id := u.ID
+
+	if _, err := notify.Lookup(typ); err != nil {
+		log.Println("procChannelRequest Lookup", err)
+		fmt.Fprint(w, err)
+		return
+	}
+
+	if typ == "wechat" && target == "" {
+		target = id
+	}
+
+	if target == "" {
+		if err := store.Ins.DeleteChannel(id, typ); err != nil {
+			log.Println("procChannelRequest DeleteChannel", err)
+			fmt.Fprint(w, err)
+			return
+		}
+	} else {
+		if err := checkTarget(typ, target); err != nil {
+			log.Println("procChannelRequest checkTarget", err)
+			fmt.Fprint(w, err)
+			return
+		}
+
+		if err := store.Ins.SetChannel(id, typ, target); err != nil {
+			log.Println("procChannelRequest SetChannel", err)
+			fmt.Fprint(w, err)
+			return
+		}
+	}
+
+	fmt.Fprintf(w, "<html><body>设置成功，<a href='/channel' target='_blank'>继续设置</a> or <a href='/?alias=%s' target='_blank'>专属链接</a></body></html>", u.Alias)
+}
+
+func checkTarget(typ, target string) error {
+	switch typ {
+	case "email":
+		if _, err := mail.ParseAddress(target); err != nil {
+			return define.ErrIllegalTarget
+		}
+	case "webhook", "dingtalk", "feishu":
+		if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return define.ErrIllegalTarget
+		}
+	}
+	if target == "" {
+		return define.ErrIllegalTarget
+	}
+	return nil
+}
+
+func procNotificationsRequest(w http.ResponseWriter, r *http.Request) {
+	u, _, ok := requireLogin(w, r, "procNotificationsRequest")
+	if !ok {
 		return
 	}
 
-	log.Println("procUnSubscribeRequest", sku, alias, password)
+	id := u.ID
 
-	var id string
+	ns, err := store.Ins.NotificationsOf(id, 100)
+	if err != nil {
+		log.Println("procNotificationsRequest Select", err)
+		fmt.Fprint(w, err)
+		return
+	}
 
-	if err := db.Ins.QueryRow("SELECT id FROM user WHERE alias = ? AND password = ?", alias, password).Scan(&id); err != nil {
-		log.Println("procUnSubscribeRequest QueryRow", err)
+	if err := notificationsPage.Execute(w, ns); err != nil {
+		log.Println("procNotificationsRequest Execute", err)
 		fmt.Fprint(w, err)
 		return
 	}
+}
+
+func procPurchaseRequest(w http.ResponseWriter, r *http.Request) {
+	u, sess, ok := requireLogin(w, r, "procPurchaseRequest")
+	if !ok {
+		return
+	}
+
+	id := u.ID
+
+	if v := r.FormValue("delete"); r.Method == http.MethodPost && v != "" {
+		pid, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			log.Println("procPurchaseRequest delete", err)
+			fmt.Fprint(w, err)
+			return
+		}
+
+		if err := store.Ins.DeletePurchase(id, pid); err != nil {
+			log.Println("procPurchaseRequest Delete", err)
+			fmt.Fprint(w, err)
+			return
+		}
+	}
+
+	if r.Method == http.MethodPost && r.FormValue("sku") != "" {
+		p, err := parsePurchase(r)
+		if err != nil {
+			log.Println("procPurchaseRequest parsePurchase", err)
+			fmt.Fprint(w, err)
+			return
+		}
 
-	if _, err := db.Ins.Exec("DELETE FROM subscribe WHERE id = ? AND sku = ?", id, sku); err != nil {
-		log.Println("procUnSubscribeRequest Exec", err)
+		p.UserID = id
+
+		log.Println("procPurchaseRequest", u.Alias, p.SkuID, p.Price, p.Quantity, p.PurchaseTime, p.Days)
+
+		if err := protect.Add(p); err != nil {
+			log.Println("procPurchaseRequest Add", err)
+			fmt.Fprint(w, err)
+			return
+		}
+	}
+
+	ps, err := store.Ins.PurchasesOf(id)
+	if err != nil {
+		log.Println("procPurchaseRequest Select", err)
 		fmt.Fprint(w, err)
 		return
 	}
 
-	fmt.Fprintf(w, "<html><body>退订成功，<a href='/unsubscribe' target='_blank'>继续退订</a> or <a href='/?alias=%s' target='_blank'>专属链接快速退订</a></body></html>", alias)
+	if err := purchasePage.Execute(w, map[string]interface{}{
+		"CSRF":      sess.CSRF,
+		"Days":      protect.DefaultDays,
+		"Purchases": ps,
+	}); err != nil {
+		log.Println("procPurchaseRequest Execute", err)
+		fmt.Fprint(w, err)
+		return
+	}
+}
+
+func parsePurchase(r *http.Request) (*define.Purchase, error) {
+	var err error
+
+	p := &define.Purchase{
+		Quantity: 1,
+		Days:     protect.DefaultDays,
+	}
+
+	if p.SkuID, err = strconv.ParseInt(r.FormValue("sku"), 10, 64); err != nil {
+		return nil, err
+	}
+
+	if !cache.Exist(p.SkuID) {
+		return nil, define.ErrNotExist
+	}
+
+	if p.Price, err = strconv.ParseFloat(r.FormValue("price"), 64); err != nil {
+		return nil, err
+	}
+
+	if v := r.FormValue("quantity"); v != "" {
+		if p.Quantity, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	if v := r.FormValue("days"); v != "" {
+		if p.Days, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", r.FormValue("date"), time.Local)
+	if err != nil {
+		return nil, err
+	}
+
+	p.PurchaseTime = t.Unix()
+
+	return p, protect.Check(p)
 }
 
 func main() {
 	captcha = make(map[string]int32)
 
 	log.SetFlags(log.Flags() | log.Lshortfile)
+
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	printConfig := flag.Bool("print-config", false, "输出生效的配置后退出")
+	config.Bind(flag.CommandLine)
+	flag.Parse()
+
+	if err := loadConfig(flag.CommandLine); err != nil {
+		log.Fatal(err)
+	}
+
+	if *printConfig {
+		if err := config.Ins.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	log.Println("Start...")
 
-	go spider.Start()
+	if err := openStore(); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go spider.Start(ctx)
+	go outbox.Start(ctx)
 	http.HandleFunc("/", procRequest)
 	http.HandleFunc("/bind", procBindRequest)
+	http.HandleFunc("/login", procLoginRequest)
+	http.HandleFunc("/logout", procLogoutRequest)
+	http.HandleFunc("/share", procShareRequest)
+	http.HandleFunc("/history", procHistoryRequest)
+	http.HandleFunc("/chart", procChartRequest)
 	http.HandleFunc("/admin", procAdminRequest)
 	http.HandleFunc("/captcha", procCaptchaRequest)
 	http.HandleFunc("/subscribe", procSubscribeRequest)
 	http.HandleFunc("/unsubscribe", procUnSubscribeRequest)
+	http.HandleFunc("/channel", procChannelRequest)
+	http.HandleFunc("/notifications", procNotificationsRequest)
+	http.HandleFunc("/purchase", procPurchaseRequest)
+	http.HandleFunc("/cart", procCartRequest)
+	http.HandleFunc("/api/v1/index", procAPIIndexRequest)
+	http.HandleFunc("/api/v1/subscriptions", procAPISubscriptionsRequest)
+	http.HandleFunc("/api/v1/users", procAPIUsersRequest)
+	http.HandleFunc("/api/v1/history", procAPIHistoryRequest)
+	http.HandleFunc("/api/v1/channels", procAPIChannelsRequest)
+	http.HandleFunc("/api/v1/notifications", procAPINotificationsRequest)
+	http.HandleFunc("/api/v1/purchases", procAPIPurchasesRequest)
+	http.HandleFunc("/api/v1/promotions", procAPIPromotionsRequest)
+	http.HandleFunc("/api/v1/quote", procAPIQuoteRequest)
+	http.HandleFunc("/api/v1/cart", procAPICartRequest)
+	http.HandleFunc("/api/v1/matrix", procAPIMatrixRequest)
+	http.HandleFunc("/api/v1/health", procAPIHealthRequest)
+	http.HandleFunc("/api/v1/spider", procAPISpiderRequest)
 	http.HandleFunc("/favicon.ico", func(http.ResponseWriter, *http.Request) {})
-	log.Fatal(http.ListenAndServe(":8080", nil))
+
+	srv := &http.Server{Addr: config.Ins.Listen}
+
+	go func() {
+		<-ctx.Done()
+		log.Println("Stop...")
+
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := srv.Shutdown(shutdown); err != nil {
+			log.Println("Shutdown", err)
+		}
+	}()
+
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+
+	spider.Wait()
 }
@@ -0,0 +1,199 @@
+package migrate
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/panshiqu/shopping/db"
+)
+
+// files 各驱动的迁移，文件名形如 0002_add_column.up.sql、0002_add_column.down.sql
+//
+//go:embed mysql/*.sql sqlite/*.sql
+var files embed.FS
+
+// ErrMissingDown 迁移缺少回滚脚本
+var ErrMissingDown = errors.New("missing down migration")
+
+// Migration 迁移
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load 加载当前驱动的迁移，按版本排序
+func Load() ([]*Migration, error) {
+	entries, err := files.ReadDir(db.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[int64]*Migration)
+	for _, v := range entries {
+		name := strings.TrimSuffix(v.Name(), ".sql")
+		n := strings.IndexByte(name, '_')
+		if n == -1 {
+			return nil, fmt.Errorf("illegal migration %s", v.Name())
+		}
+		version, err := strconv.ParseInt(name[:n], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		body, err := files.ReadFile(path.Join(db.Driver, v.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := index[version]
+		if !ok {
+			m = &Migration{Version: version}
+			index[version] = m
+		}
+		switch {
+		case strings.HasSuffix(name, ".up"):
+			m.Name, m.Up = strings.TrimSuffix(name[n+1:], ".up"), string(body)
+		case strings.HasSuffix(name, ".down"):
+			m.Down = string(body)
+		default:
+			return nil, fmt.Errorf("illegal migration %s", v.Name())
+		}
+	}
+
+	var out []*Migration
+	for _, v := range index {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Version 当前版本，未迁移时为零
+func Version() (int64, error) {
+	if _, err := db.Ins.Exec("CREATE TABLE IF NOT EXISTS schema_version (version bigint NOT NULL PRIMARY KEY, name varchar(255) NOT NULL DEFAULT '', applied_at bigint NOT NULL DEFAULT 0)"); err != nil {
+		return 0, err
+	}
+	var version int64
+	err := db.Ins.QueryRow("SELECT COALESCE(MAX(version),0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+// Up 执行全部未执行的迁移，返回执行的个数
+func Up() (int, error) {
+	ms, err := Load()
+	if err != nil {
+		return 0, err
+	}
+	version, err := Version()
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, v := range ms {
+		if v.Version <= version {
+			continue
+		}
+		if err := apply(v.Up, "INSERT INTO schema_version (version,name,applied_at) VALUES (?,?,?)", v.Version, v.Name, time.Now().Unix()); err != nil {
+			return n, fmt.Errorf("migrate %d_%s up: %v", v.Version, v.Name, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Down 从当前版本起回滚 steps 个迁移，返回回滚的个数。0001 为原 shopping.sql 的表结构，
+// 没有回滚脚本，回滚到此时返回 ErrMissingDown 而不删除数据
+func Down(steps int) (int, error) {
+	ms, err := Load()
+	if err != nil {
+		return 0, err
+	}
+	version, err := Version()
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for k := len(ms) - 1; k >= 0 && n < steps; k-- {
+		v := ms[k]
+		if v.Version > version {
+			continue
+		}
+		if v.Down == "" {
+			return n, fmt.Errorf("migrate %d_%s: %v", v.Version, v.Name, ErrMissingDown)
+		}
+		if err := apply(v.Down, "DELETE FROM schema_version WHERE version = ?", v.Version); err != nil {
+			return n, fmt.Errorf("migrate %d_%s down: %v", v.Version, v.Name, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Status 输出各迁移的执行状态
+func Status(w io.Writer) error {
+	ms, err := Load()
+	if err != nil {
+		return err
+	}
+	version, err := Version()
+	if err != nil {
+		return err
+	}
+	for _, v := range ms {
+		state := "pending"
+		if v.Version <= version {
+			state = "applied"
+		}
+		fmt.Fprintf(w, "%04d_%s %s\n", v.Version, v.Name, state)
+	}
+	return nil
+}
+
+// apply 在事务中逐条执行脚本并记录版本，MySQL 的 DDL 会隐式提交，失败时需按报错手动修复
+func apply(script, record string, args ...interface{}) error {
+	tx, err := db.Ins.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, v := range split(script) {
+		if _, err := tx.Exec(v); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// split 按行尾的分号拆分语句，忽略注释行
+func split(script string) (out []string) {
+	var sb strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+		if strings.HasSuffix(trimmed, ";") {
+			out = append(out, strings.TrimSpace(sb.String()))
+			sb.Reset()
+		}
+	}
+	if s := strings.TrimSpace(sb.String()); s != "" {
+		out = append(out, s)
+	}
+	return
+}
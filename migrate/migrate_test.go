@@ -0,0 +1,53 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/panshiqu/shopping/db"
+)
+
+func TestSplit(t *testing.T) {
+	for _, v := range []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"空脚本", "", nil},
+		{"仅注释", "-- comment\n\n-- ----\n", nil},
+		{"单条", "CREATE TABLE a (id int);", []string{"CREATE TABLE a (id int);"}},
+		{"多行语句", "-- t\nALTER TABLE a\n  ADD COLUMN b int,\n  ADD COLUMN c int;\n", []string{"ALTER TABLE a\n  ADD COLUMN b int,\n  ADD COLUMN c int;"}},
+		{"多条", "DROP TABLE a;\nDROP TABLE b;\n", []string{"DROP TABLE a;", "DROP TABLE b;"}},
+		{"末尾缺分号", "DROP TABLE a;\nDROP TABLE b", []string{"DROP TABLE a;", "DROP TABLE b"}},
+		{"分号后空格", "DROP TABLE a;  \n", []string{"DROP TABLE a;"}},
+	} {
+		if got := split(v.script); !reflect.DeepEqual(got, v.want) {
+			t.Errorf("%s: %q, want %q", v.name, got, v.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	defer func(driver string) { db.Driver = driver }(db.Driver)
+
+	versions := make(map[string][]int64)
+	for _, driver := range []string{db.MySQL, db.SQLite} {
+		db.Driver = driver
+		ms, err := Load()
+		if err != nil {
+			t.Fatal(driver, err)
+		}
+		for k, v := range ms {
+			if v.Version != int64(k+1) || v.Up == "" {
+				t.Errorf("%s: %04d_%s", driver, v.Version, v.Name)
+			}
+			if (v.Down == "") != (v.Version == 1) {
+				t.Errorf("%s: %04d_%s down %q", driver, v.Version, v.Name, v.Down)
+			}
+			versions[driver] = append(versions[driver], v.Version)
+		}
+	}
+	if !reflect.DeepEqual(versions[db.MySQL], versions[db.SQLite]) {
+		t.Errorf("versions %v", versions)
+	}
+}
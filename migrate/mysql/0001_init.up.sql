@@ -1,27 +1,9 @@
-/*
- Navicat Premium Data Transfer
-
- Source Server         : localhost
- Source Server Type    : MySQL
- Source Server Version : 50718
- Source Host           : localhost
- Source Database       : shopping
-
- Target Server Type    : MySQL
- Target Server Version : 50718
- File Encoding         : utf-8
-
- Date: 02/27/2018 22:29:50 PM
-*/
-
-SET NAMES utf8mb4;
-SET FOREIGN_KEY_CHECKS = 0;
+-- 初始表结构，即原 shopping.sql，没有回滚脚本，回滚到此为止
 
 -- ----------------------------
 --  Table structure for `jd`
 -- ----------------------------
-DROP TABLE IF EXISTS `jd`;
-CREATE TABLE `jd` (
+CREATE TABLE IF NOT EXISTS `jd` (
   `id` int(10) unsigned NOT NULL AUTO_INCREMENT COMMENT '自增编号',
   `sku` bigint(20) unsigned NOT NULL COMMENT '商品编号',
   `price` double NOT NULL COMMENT '价格',
@@ -36,8 +18,7 @@ CREATE TABLE `jd` (
 -- ----------------------------
 --  Table structure for `sku`
 -- ----------------------------
-DROP TABLE IF EXISTS `sku`;
-CREATE TABLE `sku` (
+CREATE TABLE IF NOT EXISTS `sku` (
   `sku` bigint(20) unsigned NOT NULL COMMENT '商品编号',
   `priority` int(10) unsigned NOT NULL COMMENT '优先级',
   `min_price` double NOT NULL DEFAULT '0' COMMENT '最低价',
@@ -49,8 +30,7 @@ CREATE TABLE `sku` (
 -- ----------------------------
 --  Table structure for `subscribe`
 -- ----------------------------
-DROP TABLE IF EXISTS `subscribe`;
-CREATE TABLE `subscribe` (
+CREATE TABLE IF NOT EXISTS `subscribe` (
   `id` varchar(255) NOT NULL DEFAULT '' COMMENT 'OPENID',
   `sku` bigint(20) unsigned NOT NULL COMMENT '商品编号',
   `keywords` varchar(255) NOT NULL DEFAULT '' COMMENT '关键字',
@@ -60,12 +40,9 @@ CREATE TABLE `subscribe` (
 -- ----------------------------
 --  Table structure for `user`
 -- ----------------------------
-DROP TABLE IF EXISTS `user`;
-CREATE TABLE `user` (
+CREATE TABLE IF NOT EXISTS `user` (
   `id` varchar(255) NOT NULL DEFAULT '' COMMENT 'OPENID',
   `alias` varchar(255) NOT NULL DEFAULT '' COMMENT '别名',
   `password` varchar(255) NOT NULL DEFAULT '' COMMENT '密码',
   PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
-
-SET FOREIGN_KEY_CHECKS = 1;
@@ -0,0 +1,5 @@
+ALTER TABLE `user` DROP COLUMN `area`, DROP COLUMN `admin`;
+ALTER TABLE `subscribe` DROP COLUMN `area`, DROP COLUMN `prom_alert`, DROP COLUMN `base_price`, DROP COLUMN `days`, DROP COLUMN `target`, DROP COLUMN `rule`;
+ALTER TABLE `sku` DROP COLUMN `stock`, DROP COLUMN `retailer`;
+ALTER TABLE `jd` DROP KEY `sku_record_timestamp`, DROP COLUMN `ko_end_time`, DROP COLUMN `ko_begin_time`, DROP COLUMN `breakdown`, DROP COLUMN `stock`,
+  MODIFY COLUMN `content` varchar(4096) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL DEFAULT '' COMMENT '内容';
@@ -0,0 +1,25 @@
+-- 零售商、库存状态、价格构成、秒杀时间、提醒规则、管理员及配送区域等新增列
+
+ALTER TABLE `jd`
+  ADD COLUMN `stock` tinyint(3) unsigned NOT NULL DEFAULT '1' COMMENT '库存状态：0下柜 1有货' AFTER `price`,
+  ADD COLUMN `breakdown` varchar(2048) NOT NULL DEFAULT '' COMMENT '价格构成' AFTER `stock`,
+  MODIFY COLUMN `content` varchar(4096) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL DEFAULT '' COMMENT '内容（已废弃，促销见 promotion 表）',
+  ADD COLUMN `ko_begin_time` bigint(20) NOT NULL DEFAULT '0' COMMENT '秒杀开始时间（毫秒）' AFTER `jd_page_config`,
+  ADD COLUMN `ko_end_time` bigint(20) NOT NULL DEFAULT '0' COMMENT '秒杀结束时间（毫秒）' AFTER `ko_begin_time`,
+  ADD KEY `sku_record_timestamp` (`sku`,`record_timestamp`);
+
+ALTER TABLE `sku`
+  ADD COLUMN `retailer` varchar(32) NOT NULL DEFAULT 'jd' COMMENT '零售商' AFTER `max_price`,
+  ADD COLUMN `stock` tinyint(3) unsigned NOT NULL DEFAULT '1' COMMENT '库存状态：0下柜 1有货' AFTER `retailer`;
+
+ALTER TABLE `subscribe`
+  ADD COLUMN `rule` tinyint(3) unsigned NOT NULL DEFAULT '0' COMMENT '提醒规则' AFTER `keywords`,
+  ADD COLUMN `target` double NOT NULL DEFAULT '0' COMMENT '目标价或降幅百分比' AFTER `rule`,
+  ADD COLUMN `days` int(10) unsigned NOT NULL DEFAULT '0' COMMENT 'N日均价的天数' AFTER `target`,
+  ADD COLUMN `base_price` double NOT NULL DEFAULT '0' COMMENT '订阅时价格' AFTER `days`,
+  ADD COLUMN `prom_alert` int(10) unsigned NOT NULL DEFAULT '0' COMMENT '促销变化提醒' AFTER `base_price`,
+  ADD COLUMN `area` varchar(64) NOT NULL DEFAULT '' COMMENT '配送区域，为空时使用用户的设置' AFTER `prom_alert`;
+
+ALTER TABLE `user`
+  ADD COLUMN `admin` tinyint(1) NOT NULL DEFAULT '0' COMMENT '管理员，接收页面结构变化提醒' AFTER `password`,
+  ADD COLUMN `area` varchar(64) NOT NULL DEFAULT '' COMMENT '配送区域，为空时使用默认区域' AFTER `admin`;
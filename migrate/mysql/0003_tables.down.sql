@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS `purchase`;
+DROP TABLE IF EXISTS `promotion`;
+DROP TABLE IF EXISTS `outbox`;
+DROP TABLE IF EXISTS `channel`;
+DROP TABLE IF EXISTS `area_price`;
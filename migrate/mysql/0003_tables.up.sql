@@ -0,0 +1,94 @@
+-- 分区域价格、通知渠道、通知发件箱、促销及购买记录
+
+-- ----------------------------
+--  Table structure for `area_price`
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `area_price` (
+  `sku` bigint(20) unsigned NOT NULL COMMENT '商品编号',
+  `area` varchar(64) NOT NULL DEFAULT '' COMMENT '配送区域',
+  `price` double NOT NULL COMMENT '价格',
+  `min_price` double NOT NULL DEFAULT '0' COMMENT '该区域最低价',
+  `stock` tinyint(3) unsigned NOT NULL DEFAULT '1' COMMENT '库存状态：0下柜 1有货',
+  `breakdown` varchar(2048) NOT NULL DEFAULT '' COMMENT '价格构成',
+  `promotions` text NOT NULL COMMENT '促销',
+  `update_time` bigint(20) NOT NULL DEFAULT '0' COMMENT '更新时间',
+  PRIMARY KEY (`sku`,`area`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- ----------------------------
+--  Table structure for `channel`
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `channel` (
+  `id` varchar(255) NOT NULL DEFAULT '' COMMENT 'OPENID',
+  `type` varchar(32) NOT NULL DEFAULT '' COMMENT '通知渠道',
+  `target` varchar(1024) NOT NULL DEFAULT '' COMMENT '接收地址',
+  PRIMARY KEY (`id`,`type`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- ----------------------------
+--  Table structure for `outbox`
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `outbox` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT '自增编号',
+  `user` varchar(255) NOT NULL DEFAULT '' COMMENT 'OPENID',
+  `sku` bigint(20) unsigned NOT NULL COMMENT '商品编号',
+  `type` varchar(32) NOT NULL DEFAULT '' COMMENT '通知渠道',
+  `target` varchar(1024) NOT NULL DEFAULT '' COMMENT '接收地址',
+  `message` varchar(4096) NOT NULL DEFAULT '' COMMENT '内容',
+  `dedupe` char(40) NOT NULL DEFAULT '' COMMENT '去重键',
+  `status` tinyint(3) unsigned NOT NULL DEFAULT '0' COMMENT '状态：0待投递 1已投递 2失败',
+  `attempts` int(10) unsigned NOT NULL DEFAULT '0' COMMENT '尝试次数',
+  `last_error` varchar(1024) NOT NULL DEFAULT '' COMMENT '失败原因',
+  `next_attempt` bigint(20) NOT NULL DEFAULT '0' COMMENT '下次尝试时间',
+  `create_time` bigint(20) NOT NULL DEFAULT '0' COMMENT '产生时间',
+  `deliver_time` bigint(20) NOT NULL DEFAULT '0' COMMENT '投递时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `dedupe` (`dedupe`),
+  KEY `status_next_attempt` (`status`,`next_attempt`),
+  KEY `user` (`user`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- ----------------------------
+--  Table structure for `promotion`
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `promotion` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT '自增编号',
+  `jd_id` int(10) unsigned NOT NULL COMMENT '采样编号',
+  `sku` bigint(20) unsigned NOT NULL COMMENT '商品编号',
+  `type` varchar(32) NOT NULL DEFAULT '' COMMENT '促销类型',
+  `code` varchar(32) NOT NULL DEFAULT '' COMMENT '促销标签编号',
+  `pid` varchar(64) NOT NULL DEFAULT '' COMMENT '促销编号',
+  `text` varchar(1024) NOT NULL DEFAULT '' COMMENT '描述',
+  `url` varchar(1024) NOT NULL DEFAULT '' COMMENT '链接',
+  `threshold` double NOT NULL DEFAULT '0' COMMENT '满额门槛',
+  `discount` double NOT NULL DEFAULT '0' COMMENT '减额',
+  `count` int(10) NOT NULL DEFAULT '0' COMMENT '件数',
+  `rate` double NOT NULL DEFAULT '0' COMMENT '折扣率',
+  `begin_time` varchar(32) NOT NULL DEFAULT '' COMMENT '有效期开始',
+  `end_time` varchar(32) NOT NULL DEFAULT '' COMMENT '有效期结束',
+  `each` tinyint(1) NOT NULL DEFAULT '0' COMMENT '每满',
+  `cap` double NOT NULL DEFAULT '0' COMMENT '最多可减',
+  `stackable` tinyint(1) NOT NULL DEFAULT '0' COMMENT '优惠券可与促销叠加',
+  `tiers` varchar(1024) NOT NULL DEFAULT '' COMMENT '阶梯券档位',
+  PRIMARY KEY (`id`),
+  KEY `jd_id` (`jd_id`),
+  KEY `sku_type` (`sku`,`type`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- ----------------------------
+--  Table structure for `purchase`
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `purchase` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT '自增编号',
+  `user` varchar(255) NOT NULL DEFAULT '' COMMENT 'OPENID',
+  `sku` bigint(20) unsigned NOT NULL COMMENT '商品编号',
+  `price` double NOT NULL COMMENT '购买价',
+  `quantity` int(10) unsigned NOT NULL DEFAULT '1' COMMENT '数量',
+  `purchase_time` bigint(20) NOT NULL COMMENT '购买时间',
+  `days` int(10) unsigned NOT NULL DEFAULT '7' COMMENT '价保天数',
+  `notified_price` double NOT NULL DEFAULT '0' COMMENT '已提醒的价格',
+  `create_time` bigint(20) NOT NULL DEFAULT '0' COMMENT '记录时间',
+  PRIMARY KEY (`id`),
+  KEY `sku` (`sku`),
+  KEY `user` (`user`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
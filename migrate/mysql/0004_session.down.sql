@@ -0,0 +1,2 @@
+ALTER TABLE `user` DROP KEY `share_token`, DROP COLUMN `share_token`;
+DROP TABLE IF EXISTS `session`;
@@ -0,0 +1,15 @@
+-- 登录会话及专属链接分享令牌，早期明文密码在下次登录时改存摘要
+
+-- ----------------------------
+--  Table structure for `session`
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `session` (
+  `token` char(64) NOT NULL COMMENT '会话令牌摘要',
+  `id` varchar(255) NOT NULL DEFAULT '' COMMENT 'OPENID',
+  `csrf` char(64) NOT NULL DEFAULT '' COMMENT 'CSRF 令牌',
+  `expire_timestamp` bigint(20) NOT NULL DEFAULT '0' COMMENT '过期时间',
+  PRIMARY KEY (`token`),
+  KEY `id` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE `user` ADD COLUMN `share_token` char(64) NOT NULL DEFAULT '' COMMENT '分享令牌摘要，为空时未开启分享', ADD KEY `share_token` (`share_token`);
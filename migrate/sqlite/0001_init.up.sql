@@ -0,0 +1,33 @@
+-- 初始表结构，与 MySQL 对应，时间戳列保存秒，没有回滚脚本，回滚到此为止
+
+CREATE TABLE IF NOT EXISTS `jd` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `sku` INTEGER NOT NULL,
+  `price` REAL NOT NULL,
+  `content` TEXT NOT NULL DEFAULT '',
+  `jd_price` TEXT NOT NULL DEFAULT '',
+  `jd_promotion` BLOB NOT NULL DEFAULT '',
+  `jd_page_config` BLOB NOT NULL DEFAULT '',
+  `record_timestamp` INTEGER NOT NULL DEFAULT (strftime('%s','now'))
+);
+
+CREATE TABLE IF NOT EXISTS `sku` (
+  `sku` INTEGER NOT NULL PRIMARY KEY,
+  `priority` INTEGER NOT NULL,
+  `min_price` REAL NOT NULL DEFAULT 0,
+  `max_price` REAL NOT NULL DEFAULT 0,
+  `insert_timestamp` INTEGER NOT NULL DEFAULT (strftime('%s','now'))
+);
+
+CREATE TABLE IF NOT EXISTS `subscribe` (
+  `id` TEXT NOT NULL DEFAULT '',
+  `sku` INTEGER NOT NULL,
+  `keywords` TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY (`id`,`sku`)
+);
+
+CREATE TABLE IF NOT EXISTS `user` (
+  `id` TEXT NOT NULL DEFAULT '' PRIMARY KEY,
+  `alias` TEXT NOT NULL DEFAULT '',
+  `password` TEXT NOT NULL DEFAULT ''
+);
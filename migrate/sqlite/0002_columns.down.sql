@@ -0,0 +1,15 @@
+ALTER TABLE `user` DROP COLUMN `area`;
+ALTER TABLE `user` DROP COLUMN `admin`;
+ALTER TABLE `subscribe` DROP COLUMN `area`;
+ALTER TABLE `subscribe` DROP COLUMN `prom_alert`;
+ALTER TABLE `subscribe` DROP COLUMN `base_price`;
+ALTER TABLE `subscribe` DROP COLUMN `days`;
+ALTER TABLE `subscribe` DROP COLUMN `target`;
+ALTER TABLE `subscribe` DROP COLUMN `rule`;
+ALTER TABLE `sku` DROP COLUMN `stock`;
+ALTER TABLE `sku` DROP COLUMN `retailer`;
+DROP INDEX IF EXISTS `jd_sku_record_timestamp`;
+ALTER TABLE `jd` DROP COLUMN `ko_end_time`;
+ALTER TABLE `jd` DROP COLUMN `ko_begin_time`;
+ALTER TABLE `jd` DROP COLUMN `breakdown`;
+ALTER TABLE `jd` DROP COLUMN `stock`;
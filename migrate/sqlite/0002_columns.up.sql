@@ -0,0 +1,20 @@
+-- 零售商、库存状态、价格构成、秒杀时间、提醒规则、管理员及配送区域等新增列
+
+ALTER TABLE `jd` ADD COLUMN `stock` INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE `jd` ADD COLUMN `breakdown` TEXT NOT NULL DEFAULT '';
+ALTER TABLE `jd` ADD COLUMN `ko_begin_time` INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE `jd` ADD COLUMN `ko_end_time` INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS `jd_sku_record_timestamp` ON `jd` (`sku`,`record_timestamp`);
+
+ALTER TABLE `sku` ADD COLUMN `retailer` TEXT NOT NULL DEFAULT 'jd';
+ALTER TABLE `sku` ADD COLUMN `stock` INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE `subscribe` ADD COLUMN `rule` INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE `subscribe` ADD COLUMN `target` REAL NOT NULL DEFAULT 0;
+ALTER TABLE `subscribe` ADD COLUMN `days` INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE `subscribe` ADD COLUMN `base_price` REAL NOT NULL DEFAULT 0;
+ALTER TABLE `subscribe` ADD COLUMN `prom_alert` INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE `subscribe` ADD COLUMN `area` TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE `user` ADD COLUMN `admin` INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE `user` ADD COLUMN `area` TEXT NOT NULL DEFAULT '';
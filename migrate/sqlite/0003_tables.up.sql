@@ -0,0 +1,75 @@
+-- 分区域价格、通知渠道、通知发件箱、促销及购买记录
+
+CREATE TABLE IF NOT EXISTS `area_price` (
+  `sku` INTEGER NOT NULL,
+  `area` TEXT NOT NULL DEFAULT '',
+  `price` REAL NOT NULL,
+  `min_price` REAL NOT NULL DEFAULT 0,
+  `stock` INTEGER NOT NULL DEFAULT 1,
+  `breakdown` TEXT NOT NULL DEFAULT '',
+  `promotions` TEXT NOT NULL DEFAULT '',
+  `update_time` INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (`sku`,`area`)
+);
+
+CREATE TABLE IF NOT EXISTS `channel` (
+  `id` TEXT NOT NULL DEFAULT '',
+  `type` TEXT NOT NULL DEFAULT '',
+  `target` TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY (`id`,`type`)
+);
+
+CREATE TABLE IF NOT EXISTS `outbox` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `user` TEXT NOT NULL DEFAULT '',
+  `sku` INTEGER NOT NULL,
+  `type` TEXT NOT NULL DEFAULT '',
+  `target` TEXT NOT NULL DEFAULT '',
+  `message` TEXT NOT NULL DEFAULT '',
+  `dedupe` TEXT NOT NULL DEFAULT '' UNIQUE,
+  `status` INTEGER NOT NULL DEFAULT 0,
+  `attempts` INTEGER NOT NULL DEFAULT 0,
+  `last_error` TEXT NOT NULL DEFAULT '',
+  `next_attempt` INTEGER NOT NULL DEFAULT 0,
+  `create_time` INTEGER NOT NULL DEFAULT 0,
+  `deliver_time` INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS `outbox_status_next_attempt` ON `outbox` (`status`,`next_attempt`);
+CREATE INDEX IF NOT EXISTS `outbox_user` ON `outbox` (`user`);
+
+CREATE TABLE IF NOT EXISTS `promotion` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `jd_id` INTEGER NOT NULL,
+  `sku` INTEGER NOT NULL,
+  `type` TEXT NOT NULL DEFAULT '',
+  `code` TEXT NOT NULL DEFAULT '',
+  `pid` TEXT NOT NULL DEFAULT '',
+  `text` TEXT NOT NULL DEFAULT '',
+  `url` TEXT NOT NULL DEFAULT '',
+  `threshold` REAL NOT NULL DEFAULT 0,
+  `discount` REAL NOT NULL DEFAULT 0,
+  `count` INTEGER NOT NULL DEFAULT 0,
+  `rate` REAL NOT NULL DEFAULT 0,
+  `begin_time` TEXT NOT NULL DEFAULT '',
+  `end_time` TEXT NOT NULL DEFAULT '',
+  `each` INTEGER NOT NULL DEFAULT 0,
+  `cap` REAL NOT NULL DEFAULT 0,
+  `stackable` INTEGER NOT NULL DEFAULT 0,
+  `tiers` TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS `promotion_jd_id` ON `promotion` (`jd_id`);
+CREATE INDEX IF NOT EXISTS `promotion_sku_type` ON `promotion` (`sku`,`type`);
+
+CREATE TABLE IF NOT EXISTS `purchase` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `user` TEXT NOT NULL DEFAULT '',
+  `sku` INTEGER NOT NULL,
+  `price` REAL NOT NULL,
+  `quantity` INTEGER NOT NULL DEFAULT 1,
+  `purchase_time` INTEGER NOT NULL,
+  `days` INTEGER NOT NULL DEFAULT 7,
+  `notified_price` REAL NOT NULL DEFAULT 0,
+  `create_time` INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS `purchase_sku` ON `purchase` (`sku`);
+CREATE INDEX IF NOT EXISTS `purchase_user` ON `purchase` (`user`);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS `user_share_token`;
+ALTER TABLE `user` DROP COLUMN `share_token`;
+DROP TABLE IF EXISTS `session`;
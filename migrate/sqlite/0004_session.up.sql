@@ -0,0 +1,12 @@
+-- 登录会话及专属链接分享令牌
+
+CREATE TABLE IF NOT EXISTS `session` (
+  `token` TEXT NOT NULL PRIMARY KEY,
+  `id` TEXT NOT NULL DEFAULT '',
+  `csrf` TEXT NOT NULL DEFAULT '',
+  `expire_timestamp` INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS `session_id` ON `session` (`id`);
+
+ALTER TABLE `user` ADD COLUMN `share_token` TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS `user_share_token` ON `user` (`share_token`);
@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/panshiqu/shopping/define"
+)
+
+// Email 邮件，target 为收件地址
+type Email struct {
+	Addr     string // host:port
+	From     string
+	Username string
+	Password string
+}
+
+// Name .
+func (e *Email) Name() string {
+	return "email"
+}
+
+// Notify .
+func (e *Email) Notify(target, message string) error {
+	if e.Addr == "" || e.From == "" {
+		return define.ErrNotConfigured
+	}
+
+	if strings.ContainsAny(target, "\r\n") {
+		return define.ErrIllegalTarget
+	}
+
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, err := net.SplitHostPort(e.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+
+	subject := message
+	if r := []rune(subject); len(r) > 32 {
+		subject = string(r[:32]) + "..."
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		e.From, target, mime.QEncoding.Encode("UTF-8", subject), message)
+
+	return smtp.SendMail(e.Addr, auth, e.From, []string{target}, []byte(msg))
+}
@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/store"
+)
+
+// Notifier 通知渠道
+type Notifier interface {
+	// Name 名称，对应 channel 表 type 字段
+	Name() string
+
+	// Notify 向 target 发送通知，target 含义由渠道决定
+	Notify(target, message string) error
+}
+
+// Client 通知使用的 HTTP 客户端
+var Client = &http.Client{Timeout: 10 * time.Second}
+
+var notifiers = make(map[string]Notifier)
+
+func init() {
+	Register(&WeChat{URL: "http://localhost/push"})
+	Register(&Email{})
+	Register(&Webhook{})
+	Register(&Telegram{URL: "https://api.telegram.org"})
+	Register(&DingTalk{})
+	Register(&Feishu{})
+}
+
+// Register 注册通知渠道
+func Register(n Notifier) {
+	notifiers[n.Name()] = n
+}
+
+// Lookup 查找通知渠道
+func Lookup(name string) (Notifier, error) {
+	if n, ok := notifiers[name]; ok {
+		return n, nil
+	}
+	return nil, define.ErrUnknownChannel
+}
+
+// Names 通知渠道名称
+func Names() (out []string) {
+	for k := range notifiers {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return
+}
+
+// Channels 查询用户选择的通知渠道，未选择时默认微信
+func Channels(id string) ([]*define.Channel, error) {
+	out, err := store.Ins.ChannelsOf(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(out) == 0 {
+		out = append(out, &define.Channel{Type: "wechat", Target: id})
+	}
+
+	return out, nil
+}
+
+func postJSON(url string, in interface{}, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	resp, err := Client.Post(url, "application/json; charset=utf-8", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if body, err = ioutil.ReadAll(resp.Body); err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s", resp.Status, body)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.Unmarshal(body, out)
+}
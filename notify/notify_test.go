@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/panshiqu/shopping/define"
+)
+
+func TestNotify(t *testing.T) {
+	var status int
+	var reply, path, query string
+	var body map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query, body = r.URL.Path, r.URL.RawQuery, nil
+		if in, _ := ioutil.ReadAll(r.Body); len(in) != 0 {
+			json.Unmarshal(in, &body)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(reply))
+	}))
+	defer ts.Close()
+
+	for _, v := range []struct {
+		name   string
+		n      Notifier
+		target string
+		status int
+		reply  string
+		ok     bool
+		path   string
+		query  string
+		field  string // 请求体中应包含消息的字段
+	}{
+		{"微信", &WeChat{URL: ts.URL + "/push"}, "openid", 200, "", true, "/push", "id=openid&message=hi", ""},
+		{"微信失败", &WeChat{URL: ts.URL + "/push"}, "openid", 500, "", false, "/push", "id=openid&message=hi", ""},
+		{"Webhook", &Webhook{}, ts.URL + "/hook", 200, "", true, "/hook", "", "message"},
+		{"Webhook 失败", &Webhook{}, ts.URL + "/hook", 502, "", false, "/hook", "", "message"},
+		{"Telegram", &Telegram{URL: ts.URL, Token: "t"}, "1", 200, `{"ok":true}`, true, "/bott/sendMessage", "", "text"},
+		{"Telegram 失败", &Telegram{URL: ts.URL, Token: "t"}, "1", 200, `{"ok":false,"description":"bad"}`, false, "/bott/sendMessage", "", "text"},
+		{"钉钉", &DingTalk{}, ts.URL + "/ding", 200, `{"errcode":0}`, true, "/ding", "", ""},
+		{"钉钉失败", &DingTalk{}, ts.URL + "/ding", 200, `{"errcode":310000,"errmsg":"sign"}`, false, "/ding", "", ""},
+		{"飞书", &Feishu{}, ts.URL + "/feishu", 200, `{"code":0}`, true, "/feishu", "", ""},
+		{"飞书失败", &Feishu{}, ts.URL + "/feishu", 200, `{"code":19021,"msg":"sign"}`, false, "/feishu", "", ""},
+	} {
+		status, reply, path, query = v.status, v.reply, "", ""
+		err := v.n.Notify(v.target, "hi")
+		if (err == nil) != v.ok {
+			t.Errorf("%s: %v", v.name, err)
+		}
+		if path != v.path || query != v.query {
+			t.Errorf("%s: %s?%s, want %s?%s", v.name, path, query, v.path, v.query)
+		}
+		if v.field != "" && body[v.field] != "hi" {
+			t.Errorf("%s: body %v", v.name, body)
+		}
+	}
+}
+
+func TestNotConfigured(t *testing.T) {
+	for _, v := range []struct {
+		name   string
+		n      Notifier
+		target string
+		err    error
+	}{
+		{"Telegram 未配置", &Telegram{URL: "http://127.0.0.1:0"}, "1", define.ErrNotConfigured},
+		{"邮件未配置", &Email{}, "a@b.c", define.ErrNotConfigured},
+		{"邮件地址换行", &Email{Addr: "127.0.0.1:0", From: "a@b.c"}, "a@b.c\r\nBcc: x@y.z", define.ErrIllegalTarget},
+	} {
+		if err := v.n.Notify(v.target, "hi"); err != v.err {
+			t.Errorf("%s: %v, want %v", v.name, err, v.err)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{"wechat", "email", "webhook", "telegram", "dingtalk", "feishu"} {
+		if n, err := Lookup(name); err != nil || n.Name() != name {
+			t.Errorf("Lookup(%s): %v %v", name, n, err)
+		}
+	}
+	if _, err := Lookup("sms"); err != define.ErrUnknownChannel {
+		t.Errorf("Lookup(sms): %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package notify
+
+import "fmt"
+
+// DingTalk 钉钉群机器人，target 为 Webhook 地址
+type DingTalk struct {
+}
+
+// Name .
+func (d *DingTalk) Name() string {
+	return "dingtalk"
+}
+
+// Notify .
+func (d *DingTalk) Notify(target, message string) error {
+	var resp struct {
+		ErrCode int64  `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+
+	if err := postJSON(target, map[string]interface{}{"msgtype": "text", "text": map[string]string{"content": message}}, &resp); err != nil {
+		return err
+	}
+
+	if resp.ErrCode != 0 {
+		return fmt.Errorf("dingtalk %d %s", resp.ErrCode, resp.ErrMsg)
+	}
+
+	return nil
+}
+
+// Feishu 飞书群机器人，target 为 Webhook 地址
+type Feishu struct {
+}
+
+// Name .
+func (f *Feishu) Name() string {
+	return "feishu"
+}
+
+// Notify .
+func (f *Feishu) Notify(target, message string) error {
+	var resp struct {
+		Code int64  `json:"code"`
+		Msg  string `json:"msg"`
+	}
+
+	if err := postJSON(target, map[string]interface{}{"msg_type": "text", "content": map[string]string{"text": message}}, &resp); err != nil {
+		return err
+	}
+
+	if resp.Code != 0 {
+		return fmt.Errorf("feishu %d %s", resp.Code, resp.Msg)
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/panshiqu/shopping/define"
+)
+
+// Telegram 机器人，target 为 chat_id
+type Telegram struct {
+	URL   string
+	Token string
+}
+
+// Name .
+func (t *Telegram) Name() string {
+	return "telegram"
+}
+
+// Notify .
+func (t *Telegram) Notify(target, message string) error {
+	if t.Token == "" {
+		return define.ErrNotConfigured
+	}
+
+	var resp struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+
+	if err := postJSON(fmt.Sprintf("%s/bot%s/sendMessage", t.URL, t.Token), map[string]string{"chat_id": target, "text": message}, &resp); err != nil {
+		return err
+	}
+
+	if !resp.OK {
+		return fmt.Errorf("telegram %s", resp.Description)
+	}
+
+	return nil
+}
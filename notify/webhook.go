@@ -0,0 +1,15 @@
+package notify
+
+// Webhook 通用回调，target 为回调地址，以 JSON 格式 POST
+type Webhook struct {
+}
+
+// Name .
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+// Notify .
+func (w *Webhook) Notify(target, message string) error {
+	return postJSON(target, map[string]string{"message": message}, nil)
+}
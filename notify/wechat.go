@@ -0,0 +1,34 @@
+package notify
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+)
+
+// WeChat 休闲益智游戏公众号推送，target 为 OPENID
+type WeChat struct {
+	URL string
+}
+
+// Name .
+func (w *WeChat) Name() string {
+	return "wechat"
+}
+
+// Notify .
+func (w *WeChat) Notify(target, message string) error {
+	resp, err := Client.Get(fmt.Sprintf("%s?id=%s&message=%s", w.URL, url.QueryEscape(target), url.QueryEscape(message)))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s", resp.Status, body)
+	}
+
+	return nil
+}
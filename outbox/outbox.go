@@ -0,0 +1,129 @@
+package outbox
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/notify"
+	"github.com/panshiqu/shopping/store"
+)
+
+const (
+	maxAttempts = 10
+	minBackoff  = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+	interval    = 5 * time.Second
+	batch       = 100
+	maxError    = 1024
+)
+
+// dedupe 同一天内同一渠道同一内容仅投递一次
+func dedupe(id string, c *define.Channel, message string, now time.Time) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s\n%s\n%s\n%s\n%s", now.Format("2006-01-02"), id, c.Type, c.Target, message)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Enqueue 在事务中写入待投递通知，按用户选择的渠道展开
+func Enqueue(tx store.Tx, alerts []*define.Alert) error {
+	now := time.Now()
+	var ns []*define.Notification
+	for _, v := range alerts {
+		channels, err := notify.Channels(v.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range channels {
+			ns = append(ns, &define.Notification{
+				UserID:      v.ID,
+				SkuID:       v.SkuID,
+				Type:        c.Type,
+				Target:      c.Target,
+				Message:     v.Message,
+				Dedupe:      dedupe(v.ID, c, v.Message, now),
+				NextAttempt: now.Unix(),
+				CreateTime:  now.Unix(),
+			})
+		}
+	}
+	return tx.AddNotifications(ns)
+}
+
+// backoff 指数退避
+func backoff(attempts int64) time.Duration {
+	d := minBackoff
+	for i := int64(1); i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// Start 开始投递，ctx 取消后退出
+func Start(ctx context.Context) {
+	for {
+		if err := dispatch(); err != nil {
+			log.Println("dispatch", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func dispatch() error {
+	ns, err := store.Ins.DueNotifications(time.Now().Unix(), batch)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range ns {
+		if err := deliver(v); err != nil {
+			log.Println("deliver", v.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func deliver(in *define.Notification) error {
+	n, err := notify.Lookup(in.Type)
+	if err == nil {
+		err = n.Notify(in.Target, in.Message)
+	}
+
+	now := time.Now()
+	in.Attempts++
+
+	if err == nil {
+		in.Status, in.LastError, in.DeliverTime = define.NotifyDelivered, "", now.Unix()
+		return store.Ins.UpdateNotification(in)
+	}
+
+	in.Status = define.NotifyPending
+	if in.Attempts >= maxAttempts || err == define.ErrUnknownChannel {
+		in.Status = define.NotifyFailed
+	}
+
+	in.LastError = err.Error()
+	if len(in.LastError) > maxError {
+		in.LastError = strings.ToValidUTF8(in.LastError[:maxError], "")
+	}
+	in.NextAttempt = now.Add(backoff(in.Attempts)).Unix()
+
+	if e := store.Ins.UpdateNotification(in); e != nil {
+		return e
+	}
+
+	return err
+}
@@ -0,0 +1,223 @@
+package pricing
+
+import (
+	"github.com/panshiqu/shopping/define"
+)
+
+// 计价模式
+const (
+	ModeLegacy = "legacy" // 最优优惠券折扣 × 最优促销标签折扣
+	ModeStack  = "stack"  // 按叠加规则计算最低到手单价及所需件数
+)
+
+// Default 默认计价模式，决定写入采样及最低价的价格。已保存的价格按 legacy 计算，
+// 切换模式后需执行 reprocess 重算，否则新旧价格比较会误触发提醒
+var Default = ModeLegacy
+
+// MaxQuantity 计算叠加时最多购买件数
+var MaxQuantity int64 = 20
+
+// fallback 全品类满200减10，仅 Legacy 为兼容早期价格沿用
+var fallback = &define.Promotion{
+	Type:      define.PromCoupon,
+	Text:      "全品类满200减10",
+	Threshold: 200,
+	Discount:  10,
+	Rate:      0.95,
+	Stackable: true,
+}
+
+// Modes 计价模式
+func Modes() []string {
+	return []string{ModeLegacy, ModeStack}
+}
+
+// Compute 按指定模式计算价格构成，尚未计入税费
+func Compute(mode string, proms []*define.Promotion, price float64) (*define.Breakdown, error) {
+	switch mode {
+	case ModeLegacy:
+		return Legacy(proms, price), nil
+	case ModeStack:
+		return Stack(proms, price), nil
+	}
+	return nil, define.ErrUnknownMode
+}
+
+// Quote 按默认模式计算价格构成，并附带其它模式的结果用于对比
+func Quote(proms []*define.Promotion, price float64) (*define.Breakdown, error) {
+	b, err := Compute(Default, proms, price)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range Modes() {
+		if v != Default {
+			b.Compare, _ = Compute(v, proms, price)
+		}
+	}
+	return b, nil
+}
+
+// Legacy 取最优优惠券及最优促销标签折扣
+func Legacy(proms []*define.Promotion, price float64) *define.Breakdown {
+	b := &define.Breakdown{
+		Mode:       ModeLegacy,
+		Quantity:   1,
+		ListPrice:  price,
+		Coupon:     fallback.Text,
+		CouponRate: fallback.Rate,
+		TagRate:    1,
+		Discounted: price,
+	}
+	if define.StockOf(price) == define.StockOffShelf { // 商品下柜
+		return b
+	}
+	for _, v := range proms {
+		if v.Rate == 0 {
+			continue
+		}
+		switch v.Type {
+		case define.PromCoupon:
+			if v.Rate < b.CouponRate {
+				b.Coupon, b.CouponRate = v.Text, v.Rate
+			}
+		default:
+			if v.Rate < b.TagRate {
+				b.Tag, b.TagRate = v.Text, v.Rate
+			}
+		}
+	}
+	b.Discounted = price * b.TagRate * b.CouponRate
+	return b
+}
+
+// Stack 促销标签（满减、N元选M件、多买优惠）互斥至多取一个，优惠券至多取一个，
+// 优惠券不可叠加时与促销标签二选一，逐个件数计算到手单价取最低
+func Stack(proms []*define.Promotion, price float64) *define.Breakdown {
+	b := &define.Breakdown{
+		Mode:       ModeStack,
+		Quantity:   1,
+		ListPrice:  price,
+		CouponRate: 1,
+		TagRate:    1,
+		Discounted: price,
+	}
+	if define.StockOf(price) == define.StockOffShelf { // 商品下柜
+		return b
+	}
+
+	tags := []*define.Promotion{nil}
+	coupons := []*define.Promotion{nil}
+	for _, v := range proms {
+		switch v.Type {
+		case define.PromFullReduction, define.PromPickN, define.PromMultiBuy:
+			tags = append(tags, v)
+		case define.PromCoupon, define.PromStepCoupon:
+			coupons = append(coupons, v)
+		case define.PromGift:
+			b.Gifts = append(b.Gifts, v.Text)
+		}
+	}
+
+	for q := int64(1); q <= MaxQuantity; q++ {
+		total := price * float64(q)
+		for _, t := range tags {
+			afterTag, ok := applyTag(t, price, q)
+			if !ok {
+				continue
+			}
+			for _, c := range coupons {
+				if c != nil && t != nil && !c.Stackable {
+					continue
+				}
+				afterCoupon, ok := applyCoupon(c, afterTag)
+				if !ok {
+					continue
+				}
+				if unit := afterCoupon / float64(q); unit < b.Discounted-1e-9 {
+					b.Quantity = q
+					b.Discounted = unit
+					b.Tag, b.TagRate = text(t), afterTag/total
+					b.Coupon, b.CouponRate = text(c), afterCoupon/afterTag
+				}
+			}
+		}
+	}
+
+	return b
+}
+
+func text(in *define.Promotion) string {
+	if in == nil {
+		return ""
+	}
+	return in.Text
+}
+
+// applyTag 购买 q 件时应用促销标签后的总价
+func applyTag(t *define.Promotion, price float64, q int64) (float64, bool) {
+	total := price * float64(q)
+	if t == nil {
+		return total, true
+	}
+	switch t.Type {
+	case define.PromFullReduction:
+		reduction := Reduction(t, total)
+		if reduction == 0 {
+			return 0, false
+		}
+		return total - reduction, true
+	case define.PromPickN:
+		if t.Count <= 0 || q < t.Count {
+			return 0, false
+		}
+		groups := q / t.Count
+		return float64(groups)*t.Threshold + float64(q-groups*t.Count)*price, true
+	case define.PromMultiBuy:
+		if t.Rate <= 0 || t.Count <= 0 || q < t.Count {
+			return 0, false
+		}
+		return total * t.Rate, true
+	}
+	return 0, false
+}
+
+// Reduction 满减金额，未达门槛时为零
+func Reduction(t *define.Promotion, total float64) float64 {
+	if t.Threshold <= 0 || total < t.Threshold {
+		return 0
+	}
+	reduction := t.Discount
+	if t.Each {
+		reduction = float64(int64(total/t.Threshold)) * t.Discount
+	}
+	if t.Cap > 0 && reduction > t.Cap {
+		reduction = t.Cap
+	}
+	return reduction
+}
+
+// applyCoupon 对应用促销标签后的总价使用优惠券
+func applyCoupon(c *define.Promotion, total float64) (float64, bool) {
+	if c == nil {
+		return total, true
+	}
+	switch c.Type {
+	case define.PromCoupon:
+		if total < c.Threshold || total <= c.Discount {
+			return 0, false
+		}
+		return total - c.Discount, true
+	case define.PromStepCoupon:
+		var best float64
+		for _, v := range c.Tiers {
+			if total >= v.Quota && v.Discount > best && v.Discount < total {
+				best = v.Discount
+			}
+		}
+		if best == 0 {
+			return 0, false
+		}
+		return total - best, true
+	}
+	return 0, false
+}
@@ -0,0 +1,50 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+
+	"github.com/panshiqu/shopping/define"
+)
+
+func TestLegacy(t *testing.T) {
+	for _, v := range []struct {
+		name       string
+		proms      []*define.Promotion
+		price      float64
+		discounted float64
+		coupon     string
+	}{
+		{"兜底优惠券", nil, 100, 95, fallback.Text},
+		{"更优优惠券及促销标签", []*define.Promotion{{Type: define.PromCoupon, Text: "满100减20", Rate: 0.8}, {Type: define.PromMultiBuy, Text: "2件9折", Rate: 0.9}}, 100, 72, "满100减20"},
+		{"下柜", nil, -1, -1, fallback.Text},
+	} {
+		b := Legacy(v.proms, v.price)
+		if math.Abs(b.Discounted-v.discounted) > 1e-9 || b.Coupon != v.coupon {
+			t.Errorf("%s: %v %s, want %v %s", v.name, b.Discounted, b.Coupon, v.discounted, v.coupon)
+		}
+	}
+}
+
+func TestStack(t *testing.T) {
+	for _, v := range []struct {
+		name       string
+		proms      []*define.Promotion
+		price      float64
+		discounted float64
+		quantity   int64
+	}{
+		{"无促销不使用兜底优惠券", nil, 300, 300, 1},
+		{"满减", []*define.Promotion{{Type: define.PromFullReduction, Text: "满100减20", Threshold: 100, Discount: 20}}, 100, 80, 1},
+		{"可叠加优惠券", []*define.Promotion{{Type: define.PromFullReduction, Text: "满100减20", Threshold: 100, Discount: 20}, {Type: define.PromCoupon, Text: "满80减10", Threshold: 80, Discount: 10, Stackable: true}}, 100, 70, 1},
+		{"不可叠加优惠券二选一", []*define.Promotion{{Type: define.PromFullReduction, Text: "满100减20", Threshold: 100, Discount: 20}, {Type: define.PromCoupon, Text: "满80减30", Threshold: 80, Discount: 30}}, 100, 70, 1},
+		{"N元选M件", []*define.Promotion{{Type: define.PromPickN, Text: "99元选3件", Threshold: 99, Count: 3}}, 50, 33, 3},
+		{"多买优惠件数为零不适用", []*define.Promotion{{Type: define.PromMultiBuy, Text: "多买优惠", Rate: 0.5}}, 100, 100, 1},
+		{"下柜", []*define.Promotion{{Type: define.PromFullReduction, Threshold: 1, Discount: 1}}, -1, -1, 1},
+	} {
+		b := Stack(v.proms, v.price)
+		if math.Abs(b.Discounted-v.discounted) > 1e-9 || b.Quantity != v.quantity {
+			t.Errorf("%s: %v×%d, want %v×%d", v.name, b.Discounted, b.Quantity, v.discounted, v.quantity)
+		}
+	}
+}
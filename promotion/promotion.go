@@ -0,0 +1,59 @@
+package promotion
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/panshiqu/shopping/define"
+)
+
+// Equal 促销是否相同
+func Equal(a, b []*define.Promotion) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if !reflect.DeepEqual(v, b[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter 按类型及关键字筛选促销
+func Filter(in []*define.Promotion, typ, keyword string) (out []*define.Promotion) {
+	for _, v := range in {
+		if typ != "" && v.Type != typ {
+			continue
+		}
+		if keyword != "" && !strings.Contains(v.Text, keyword) {
+			continue
+		}
+		out = append(out, v)
+	}
+	return
+}
+
+// Diff 比较促销变化，以类型及描述区分
+func Diff(prev, cur []*define.Promotion) (added, removed []*define.Promotion) {
+	key := func(p *define.Promotion) string {
+		return p.Type + "\n" + p.Text
+	}
+	old := make(map[string]bool)
+	for _, v := range prev {
+		old[key(v)] = true
+	}
+	now := make(map[string]bool)
+	for _, v := range cur {
+		now[key(v)] = true
+		if !old[key(v)] {
+			added = append(added, v)
+		}
+	}
+	for _, v := range prev {
+		if !now[key(v)] {
+			removed = append(removed, v)
+		}
+	}
+	return
+}
@@ -0,0 +1,59 @@
+package protect
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/store"
+)
+
+// DefaultDays 默认价保天数
+const DefaultDays = 7
+
+// Check 校验购买记录
+func Check(p *define.Purchase) error {
+	if p.Price <= 0 || p.Quantity <= 0 || p.Days <= 0 || p.PurchaseTime <= 0 {
+		return define.ErrIllegalPurchase
+	}
+	return nil
+}
+
+// Add 校验并记录购买
+func Add(p *define.Purchase) error {
+	if err := Check(p); err != nil {
+		return err
+	}
+	return store.Ins.AddPurchase(p)
+}
+
+// Match 查找价保期内购买价高于当前价格且尚未就该价格提醒过的购买记录
+func Match(page *define.Page, price float64) ([]*define.Purchase, []*define.Alert, error) {
+	if price <= 0 { // 商品未下柜
+		return nil, nil, nil
+	}
+
+	ps, err := store.Ins.ProtectedPurchases(page.SkuID, price, time.Now().Unix())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var out []*define.Purchase
+	var alerts []*define.Alert
+	for _, v := range ps {
+		if v.NotifiedPrice != 0 && price >= v.NotifiedPrice {
+			continue
+		}
+
+		out = append(out, v)
+		alerts = append(alerts, &define.Alert{
+			ID:    v.UserID,
+			SkuID: v.SkuID,
+			Message: fmt.Sprintf("%s已降至%.2f，低于您%s的购买价%.2f，可申请价保%.2f元（%s前有效） %s",
+				page.Name, price, time.Unix(v.PurchaseTime, 0).Format("01-02"), v.Price, (v.Price-price)*float64(v.Quantity),
+				time.Unix(v.PurchaseTime+v.Days*24*60*60, 0).Format("01-02 15:04"), page.URL),
+		})
+	}
+
+	return out, alerts, nil
+}
@@ -0,0 +1,30 @@
+package region
+
+import (
+	"regexp"
+
+	"github.com/panshiqu/shopping/define"
+)
+
+// Default 部署的默认配送区域（省_市_区_街道）
+var Default = "7_412_47301_0"
+
+var pattern = regexp.MustCompile(`^\d+_\d+_\d+_\d+$`)
+
+// Check 校验配送区域，为空表示使用默认区域
+func Check(area string) error {
+	if area == "" || pattern.MatchString(area) {
+		return nil
+	}
+	return define.ErrIllegalArea
+}
+
+// Effective 实际使用的配送区域，依次取订阅、用户、部署的设置
+func Effective(in ...string) string {
+	for _, v := range in {
+		if v != "" {
+			return v
+		}
+	}
+	return Default
+}
@@ -0,0 +1,115 @@
+package spider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/panshiqu/shopping/alert"
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/outbox"
+	"github.com/panshiqu/shopping/promotion"
+	"github.com/panshiqu/shopping/region"
+	"github.com/panshiqu/shopping/store"
+)
+
+// MultiArea 为订阅者所在的其他配送区域单独采样价格及促销
+var MultiArea = false
+
+// MaxAreas 每个商品额外采样的区域数上限，按订阅数优先，其余订阅按默认区域提醒
+var MaxAreas = 4
+
+// assign 按实际配送区域为订阅分组，返回额外采样的区域，未采样区域的订阅归入默认区域
+func assign(subs []*define.Subscription) (map[string][]*define.Subscription, []string) {
+	groups := make(map[string][]*define.Subscription)
+	for _, v := range subs {
+		groups[v.Area] = append(groups[v.Area], v)
+	}
+
+	var areas []string
+	for k := range groups {
+		if k != region.Default {
+			areas = append(areas, k)
+		}
+	}
+	sort.Slice(areas, func(i, j int) bool {
+		if len(groups[areas[i]]) != len(groups[areas[j]]) {
+			return len(groups[areas[i]]) > len(groups[areas[j]])
+		}
+		return areas[i] < areas[j]
+	})
+
+	n := 0
+	if MultiArea {
+		n = MaxAreas
+	}
+	if n > len(areas) {
+		n = len(areas)
+	}
+	for _, v := range areas[n:] {
+		groups[region.Default] = append(groups[region.Default], groups[v]...)
+		delete(groups, v)
+	}
+	return groups, areas[:n]
+}
+
+// crawlArea 采样其他配送区域的价格及促销，税费与默认区域相同，变化时更新价格矩阵并提醒该区域的订阅者
+func crawlArea(ctx context.Context, r Retailer, rs *result, area string, subs []*define.Subscription) error {
+	page := *rs.page
+	page.Area = area
+	price, _, err := r.ResolvePrice(ctx, &page)
+	if err != nil {
+		return err
+	}
+	proms, b, _, err := r.ResolvePromotion(ctx, &page, price)
+	if err != nil {
+		return err
+	}
+	b.Tax = rs.b.Tax
+	price = b.Round()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	prev, err := store.Ins.RegionPrice(page.SkuID, area)
+	if err != nil {
+		return err
+	}
+	if prev.UpdateTime != 0 && price == prev.Price && promotion.Equal(proms, prev.Promotions) {
+		return nil
+	}
+
+	rp := &define.RegionPrice{
+		SkuID:      page.SkuID,
+		Area:       area,
+		Price:      price,
+		MinPrice:   prev.MinPrice,
+		Stock:      define.StockOf(price),
+		Breakdown:  b,
+		Promotions: proms,
+	}
+	c := &define.Change{
+		Push:           price == prev.MinPrice && prev.Price != prev.MinPrice,
+		PrevPrice:      prev.Price,
+		PrevPromotions: prev.Promotions,
+	}
+	if rp.Stock == define.StockInStock && (price < rp.MinPrice || rp.MinPrice == 0) {
+		c.Push = true
+		rp.MinPrice = price
+	}
+
+	alerts, err := alert.Match(subs, &page, price, proms, c)
+	if err != nil {
+		return err
+	}
+	for _, v := range alerts {
+		v.Message = fmt.Sprintf("【配送至%s】%s", area, v.Message)
+	}
+
+	return store.Ins.Atomic(ctx, func(tx store.Tx) error {
+		if err := tx.SaveRegionPrice(rp); err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, alerts)
+	})
+}
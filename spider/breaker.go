@@ -0,0 +1,86 @@
+package spider
+
+import (
+	"sync"
+	"time"
+
+	"github.com/panshiqu/shopping/define"
+)
+
+var (
+	// BreakerThreshold 商品连续请求失败多少次后熔断
+	BreakerThreshold = 5
+
+	// BreakerCooldown 首次熔断时长，再次熔断时翻倍
+	BreakerCooldown = 10 * time.Minute
+
+	// MaxBreakerCooldown 熔断时长上限
+	MaxBreakerCooldown = 6 * time.Hour
+)
+
+type breaker struct {
+	failures  int
+	cooldown  time.Duration
+	openUntil time.Time
+}
+
+var (
+	breakerMtx sync.Mutex
+	breakers   = make(map[int64]*breaker)
+	tripped    int64
+)
+
+// allow 熔断期间拒绝抓取，到期后放行一次试探
+func allow(sku int64) error {
+	breakerMtx.Lock()
+	defer breakerMtx.Unlock()
+	if b, ok := breakers[sku]; ok && time.Now().Before(b.openUntil) {
+		return define.ErrCircuitOpen
+	}
+	return nil
+}
+
+// report 记录抓取结果，成功时复位，仅网络及可重试的请求错误计入失败，解析、校验及存储错误不影响熔断
+func report(sku int64, err error) {
+	if err != nil && !retryable(err) {
+		return
+	}
+
+	breakerMtx.Lock()
+	defer breakerMtx.Unlock()
+
+	if err == nil {
+		delete(breakers, sku)
+		return
+	}
+
+	b, ok := breakers[sku]
+	if !ok {
+		b = &breaker{}
+		breakers[sku] = b
+	}
+
+	if b.failures++; b.failures < BreakerThreshold {
+		return
+	}
+
+	if b.cooldown == 0 {
+		b.cooldown = BreakerCooldown
+	} else if b.cooldown *= 2; b.cooldown > MaxBreakerCooldown {
+		b.cooldown = MaxBreakerCooldown
+	}
+	b.openUntil = time.Now().Add(b.cooldown)
+	tripped++
+}
+
+func breakerStats() (open int, total int64) {
+	breakerMtx.Lock()
+	defer breakerMtx.Unlock()
+	now := time.Now()
+	for _, v := range breakers {
+		if now.Before(v.openUntil) {
+			open++
+		}
+	}
+	return open, tripped
+}
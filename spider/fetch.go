@@ -0,0 +1,111 @@
+package spider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+var (
+	// Timeout 单次请求超时
+	Timeout = 10 * time.Second
+
+	// MaxRetries 可重试错误的最多重试次数
+	MaxRetries = 3
+
+	// RetryBackoff 首次重试等待，之后逐次翻倍并加入随机抖动
+	RetryBackoff = 500 * time.Millisecond
+)
+
+// StatusError 非 2xx 响应
+type StatusError struct {
+	URL  string
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %d %s", e.URL, e.Code, http.StatusText(e.Code))
+}
+
+// retryable 网络错误、单次请求超时、5xx 及 429 可重试，取消及其它状态码不重试
+func retryable(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Code >= 500 || se.Code == http.StatusTooManyRequests
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// sleep 等待 d 或 ctx 取消
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// jitter 在 d 的基础上加入 [0, d) 的随机抖动，d 不为正时不等待
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d + time.Duration(rand.Int63n(int64(d)))
+}
+
+func fetchURL(ctx context.Context, in string) ([]byte, error) {
+	backoff := RetryBackoff
+	for attempt := 0; ; attempt++ {
+		body, err := fetchOnce(ctx, in)
+		if err == nil {
+			return body, nil
+		}
+		if attempt >= MaxRetries || ctx.Err() != nil || !retryable(err) {
+			return nil, err
+		}
+		if err := sleep(ctx, jitter(backoff)); err != nil {
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+func fetchOnce(ctx context.Context, in string) ([]byte, error) {
+	if err := wait(ctx, in); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, in, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, &StatusError{URL: in, Code: resp.StatusCode}
+	}
+	return ioutil.ReadAll(resp.Body)
+}
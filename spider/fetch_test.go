@@ -0,0 +1,92 @@
+package spider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/panshiqu/shopping/define"
+)
+
+func TestRetryable(t *testing.T) {
+	for _, v := range []struct {
+		err  error
+		want bool
+	}{
+		{&StatusError{Code: http.StatusInternalServerError}, true},
+		{&StatusError{Code: http.StatusTooManyRequests}, true},
+		{&StatusError{Code: http.StatusNotFound}, false},
+		{fmt.Errorf("wrap: %w", &StatusError{Code: http.StatusBadGateway}), true},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, true},
+		{io.ErrUnexpectedEOF, true},
+		{&net.OpError{Op: "dial", Err: errors.New("refused")}, true},
+		{errors.New("parse"), false},
+	} {
+		if got := retryable(v.err); got != v.want {
+			t.Errorf("retryable(%v) = %v, want %v", v.err, got, v.want)
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for _, d := range []time.Duration{-time.Second, 0} {
+		if got := jitter(d); got != 0 {
+			t.Errorf("jitter(%v) = %v", d, got)
+		}
+	}
+	if got := jitter(time.Second); got < time.Second || got >= 2*time.Second {
+		t.Errorf("jitter(1s) = %v", got)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	const sku = -1
+	defer report(sku, nil)
+
+	fail := &StatusError{Code: http.StatusServiceUnavailable}
+
+	// 解析等非请求错误不计入熔断
+	for i := 0; i < BreakerThreshold; i++ {
+		report(sku, errors.New("parse"))
+	}
+	if err := allow(sku); err != nil {
+		t.Fatalf("parse errors: %v", err)
+	}
+
+	for i := 0; i < BreakerThreshold; i++ {
+		if err := allow(sku); err != nil {
+			t.Fatalf("failure %d: %v", i, err)
+		}
+		report(sku, fail)
+	}
+	if err := allow(sku); err != define.ErrCircuitOpen {
+		t.Fatalf("allow = %v, want ErrCircuitOpen", err)
+	}
+
+	breakerMtx.Lock()
+	breakers[sku].openUntil = time.Now()
+	breakerMtx.Unlock()
+	if err := allow(sku); err != nil {
+		t.Fatalf("half open: %v", err)
+	}
+
+	// 试探失败再次熔断，时长翻倍
+	report(sku, fail)
+	breakerMtx.Lock()
+	cooldown := breakers[sku].cooldown
+	breakerMtx.Unlock()
+	if cooldown != 2*BreakerCooldown {
+		t.Fatalf("cooldown = %v", cooldown)
+	}
+
+	report(sku, nil)
+	if err := allow(sku); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+}
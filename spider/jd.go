@@ -0,0 +1,526 @@
+package spider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/health"
+	"github.com/panshiqu/shopping/pricing"
+	"github.com/panshiqu/shopping/region"
+	"github.com/robertkrimen/otto"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/transform"
+)
+
+// JDEndpoints 京东接口地址
+type JDEndpoints struct {
+	Item      string // 商品页
+	Price     string // 价格
+	Promotion string // 促销
+	Tax       string // 全球购税费
+}
+
+// JD 京东接口地址，可指向测试服务器
+var JD = &JDEndpoints{
+	Item:      "https://item.jd.com",
+	Price:     "https://p.3.cn",
+	Promotion: "https://cd.jd.com",
+	Tax:       "https://c.3.cn",
+}
+
+// jdRetailer 京东
+type jdRetailer struct {
+}
+
+func (j *jdRetailer) Name() string {
+	return "jd"
+}
+
+func (j *jdRetailer) FetchPage(ctx context.Context, sku int64) (*define.Page, error) {
+	body, err := fetchURL(ctx, fmt.Sprintf("%s/%d.html", JD.Item, sku))
+	if err != nil {
+		return nil, err
+	}
+	pc, err := getPageConfig(body)
+	if err != nil {
+		sample, _ := gbk2utf8(body)
+		health.Record("jd.page", sku, err, sample)
+		return nil, err
+	}
+	health.Record("jd.page", sku, nil, nil)
+	pc, err = gbk2utf8(pc)
+	if err != nil {
+		return nil, err
+	}
+	page, err := j.ReplayPage(sku, pc)
+	health.Record("jd.pageConfig", sku, err, pc)
+	if err != nil {
+		return nil, err
+	}
+	validatePage(page)
+	return page, nil
+}
+
+func (j *jdRetailer) ReplayPage(sku int64, pc []byte) (*define.Page, error) {
+	jdpc, missing, err := decodePageConfig(pc)
+	if err != nil {
+		return nil, err
+	}
+	return &define.Page{
+		SkuID:       sku,
+		Name:        jdpc.Name,
+		URL:         fmt.Sprintf("%s/%d.html", JD.Item, sku),
+		KoBeginTime: jdpc.KoBeginTime,
+		KoEndTime:   jdpc.KoEndTime,
+		Src:         jdpc.Src,
+		Area:        region.Default,
+		Raw:         pc,
+		Config:      jdpc,
+		Missing:     missing,
+	}, nil
+}
+
+func (j *jdRetailer) ResolvePrice(ctx context.Context, page *define.Page) (float64, []byte, error) {
+	pdt, err := getJDPrice(ctx, page.Config.(*define.JDPageConfig), page.Area)
+	if err != nil {
+		return 0, nil, err
+	}
+	price, err := j.ReplayPrice(page, pdt)
+	health.Record("jd.price", page.SkuID, err, pdt)
+	if err != nil {
+		return 0, nil, err
+	}
+	health.Check("jd.price.p", page.SkuID, price > 0 || price == -1, pdt)
+	return price, pdt, nil
+}
+
+func (j *jdRetailer) ReplayPrice(page *define.Page, pdt []byte) (float64, error) {
+	jdp, err := parseJDPrice(pdt)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(jdp.Price, 64)
+}
+
+func (j *jdRetailer) ResolvePromotion(ctx context.Context, page *define.Page, price float64) ([]*define.Promotion, *define.Breakdown, []byte, error) {
+	idt, err := getJDInfo(ctx, page.Config.(*define.JDPageConfig), page.Area)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	proms, b, err := j.ReplayPromotion(page, idt, price)
+	health.Record("jd.promotion", page.SkuID, err, idt)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	validatePromotions(page.SkuID, proms, idt)
+	return proms, b, idt, nil
+}
+
+func (j *jdRetailer) ReplayPromotion(page *define.Page, idt []byte, price float64) ([]*define.Promotion, *define.Breakdown, error) {
+	jdi, err := parseJDInfo(idt)
+	if err != nil {
+		return nil, nil, err
+	}
+	proms := parsePromotions(jdi, price)
+	b, err := pricing.Quote(proms, price)
+	if err != nil {
+		return nil, nil, err
+	}
+	return proms, b, nil
+}
+
+func (j *jdRetailer) ResolveTax(ctx context.Context, page *define.Page) (float64, error) {
+	body, err := getJDTax(ctx, page.SkuID)
+	if err != nil {
+		return 0, err
+	}
+	tax, err := parseJDTax(body)
+	health.Record("jd.tax", page.SkuID, err, body)
+	return tax, err
+}
+
+func getPageConfig(in []byte) ([]byte, error) {
+	begin := bytes.Index(in, []byte("var pageConfig"))
+	if begin == -1 {
+		return nil, errors.New("Index begin")
+	}
+	in = in[begin:]
+	end := bytes.Index(in, []byte("};"))
+	if end == -1 {
+		return nil, errors.New("Index end")
+	}
+	return in[:end+2], nil
+}
+
+func gbk2utf8(in []byte) ([]byte, error) {
+	return ioutil.ReadAll(transform.NewReader(bytes.NewReader(in), simplifiedchinese.GBK.NewDecoder()))
+}
+
+func getInt(vm *otto.Otto, in string) (int64, bool) {
+	if v, err := vm.Run(in); err == nil && v.IsDefined() && !v.IsNull() {
+		if v, err := v.ToInteger(); err == nil {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+func getString(vm *otto.Otto, in string) (string, bool) {
+	if v, err := vm.Run(in); err == nil && v.IsString() {
+		if v, err := v.ToString(); err == nil {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func getIntSlice(vm *otto.Otto, in string) []int64 {
+	if v, err := vm.Run(in); err == nil {
+		if v, err := v.Export(); err == nil {
+			if v, ok := v.([]int64); ok {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// js2Go 用 otto 执行页面配置，返回缺失的字段
+func js2Go(in []byte) (*define.JDPageConfig, []string, error) {
+	vm := otto.New()
+	if _, err := vm.Run(in); err != nil {
+		return nil, nil, err
+	}
+	var missing []string
+	jdpc := &define.JDPageConfig{}
+	var ok bool
+	if jdpc.SkuID, ok = getInt(vm, "pageConfig.product.skuid"); !ok {
+		missing = append(missing, "skuid")
+	}
+	if jdpc.Name, ok = getString(vm, "pageConfig.product.name"); !ok {
+		missing = append(missing, "name")
+	}
+	if jdpc.KoBeginTime, ok = getInt(vm, "pageConfig.product.koBeginTime"); !ok {
+		missing = append(missing, "koBeginTime")
+	}
+	if jdpc.KoEndTime, ok = getInt(vm, "pageConfig.product.koEndTime"); !ok {
+		missing = append(missing, "koEndTime")
+	}
+	src, ok := getString(vm, "pageConfig.product.src")
+	if !ok {
+		missing = append(missing, "src")
+	}
+	jdpc.Src = fmt.Sprintf("http://img14.360buyimg.com/n1/%s", src)
+	if jdpc.Cat = getIntSlice(vm, "pageConfig.product.cat"); len(jdpc.Cat) == 0 {
+		missing = append(missing, "cat")
+	}
+	return jdpc, missing, nil
+}
+
+// decodePageConfig 优先使用轻量解析器，语法错误或缺失必需字段时回退到 otto
+func decodePageConfig(in []byte) (*define.JDPageConfig, []string, error) {
+	jdpc, missing, err := parsePageConfig(in)
+	if err == nil {
+		var fallback bool
+		for _, v := range missing {
+			fallback = fallback || requiredFields[v]
+		}
+		if !fallback {
+			return jdpc, missing, nil
+		}
+	}
+	log.Println("decodePageConfig parsePageConfig", err, missing)
+	return js2Go(in)
+}
+
+func getJDPrice(ctx context.Context, in *define.JDPageConfig, area string) ([]byte, error) {
+	return fetchURL(ctx, fmt.Sprintf("%s/prices/mgets?area=%s&pduid=%d&skuIds=J_%d", JD.Price, region.Effective(area), time.Now().UnixNano(), in.SkuID))
+}
+
+func parseJDPrice(in []byte) (*define.JDPrice, error) {
+	var jdps []*define.JDPrice
+	if err := json.Unmarshal(in, &jdps); err != nil {
+		return nil, err
+	}
+	if len(jdps) == 0 {
+		return nil, errors.New("Empty price")
+	}
+	return jdps[0], nil
+}
+
+// getJDInfo 返回转换为 UTF-8 的促销信息
+func getJDInfo(ctx context.Context, in *define.JDPageConfig, area string) ([]byte, error) {
+	body, err := fetchURL(ctx, fmt.Sprintf("%s/promotion/v2?skuId=%d&area=%s&cat=%s", JD.Promotion, in.SkuID, region.Effective(area), in.JoinCat()))
+	if err != nil {
+		return nil, err
+	}
+	return gbk2utf8(body)
+}
+
+func parseJDInfo(in []byte) (*define.JDInfo, error) {
+	jdi := &define.JDInfo{}
+	if err := json.Unmarshal(in, jdi); err != nil {
+		return nil, err
+	}
+	if jdi.Prom == nil {
+		return nil, errors.New("Missing prom")
+	}
+	if len(jdi.Quan) == 0 {
+		return jdi, nil
+	}
+	if jdi.Quan[0] == '[' {
+		if err := json.Unmarshal(jdi.Quan, &jdi.Quans); err != nil {
+			return nil, err
+		}
+	} else {
+		jdq := &define.JDQuan{}
+		if err := json.Unmarshal(jdi.Quan, jdq); err != nil {
+			return nil, err
+		}
+		jdi.Quans = append(jdi.Quans, jdq)
+	}
+	return jdi, nil
+}
+
+// getJDTax 返回转换为 UTF-8 的全球购信息
+func getJDTax(ctx context.Context, in int64) ([]byte, error) {
+	body, err := fetchURL(ctx, fmt.Sprintf("%s/globalBuy?skuId=%d", JD.Tax, in))
+	if err != nil {
+		return nil, err
+	}
+	return gbk2utf8(body)
+}
+
+func parseJDTax(in []byte) (float64, error) {
+	jdgb := &define.JDGlobalBuy{}
+	if err := json.Unmarshal(in, jdgb); err != nil {
+		return 0, err
+	}
+	if !jdgb.Success {
+		return 0, nil
+	}
+	if jdgb.TaxTxt == nil {
+		return 0, errors.New("Missing taxTxt")
+	}
+	pos := strings.Index(jdgb.TaxTxt.Content, "￥")
+	if pos == -1 {
+		return 0, errors.New("Index pos")
+	}
+	return strconv.ParseFloat(jdgb.TaxTxt.Content[pos+3:], 64)
+}
+
+func parsePromotions(jdi *define.JDInfo, price float64) []*define.Promotion {
+	var out []*define.Promotion
+	for _, v := range jdi.SkuCoupon {
+		p := &define.Promotion{
+			Threshold: float64(v.Quota),
+			Discount:  float64(v.Discount),
+			URL:       v.URL,
+			Begin:     v.BeginTime,
+			End:       v.EndTime,
+			Stackable: !strings.Contains(v.OverlapDesc, "不可"),
+		}
+		switch v.CouponStyle {
+		case 0:
+			p.Type = define.PromCoupon
+			p.Text = fmt.Sprintf("【满%d减%d】%s %s %s", v.Quota, v.Discount, v.TimeDesc, v.Name, v.OverlapDesc)
+			quota := float64(v.Quota)
+			if price > quota {
+				quota = price
+			}
+			p.Rate = (quota - float64(v.Discount)) / quota
+		case 3:
+			p.Type = define.PromStepCoupon
+			p.Text = fmt.Sprintf("##【%s-%s】%s %s %s", v.AllDesc, v.HighDesc, v.TimeDesc, v.Name, v.OverlapDesc)
+			p.Tiers = parseTiers(v.DiscountJSON)
+		default:
+			p.Type = define.PromUnknown
+			p.Text = fmt.Sprintf("Unknown Coupon Style: %d", v.CouponStyle)
+		}
+		out = append(out, p)
+	}
+	for _, v := range jdi.Ads {
+		if v.Ad != "" {
+			out = append(out, &define.Promotion{Type: define.PromAd, Text: v.Ad})
+		}
+	}
+	for _, v := range jdi.Quans {
+		out = append(out, &define.Promotion{Type: define.PromQuan, Text: fmt.Sprintf("【满额返券】%s", v.Title), URL: v.ActURL})
+	}
+	tags := append(jdi.Prom.PickOneTag, jdi.Prom.Tags...)
+	sort.Sort(define.TagsSlice(tags))
+	return append(out, parseTags(tags, price)...)
+}
+
+func parseTags(tags []*define.JDTag, price float64) []*define.Promotion {
+	var out []*define.Promotion
+	for _, v := range tags {
+		if len(v.Gifts) != 0 {
+			for _, vv := range v.Gifts {
+				out = append(out, &define.Promotion{
+					Type:  define.PromGift,
+					Code:  v.Code,
+					Pid:   v.Pid,
+					Text:  fmt.Sprintf("【%s】%sX%d%s", v.Name, vv.Nm, vv.Num, v.Content),
+					URL:   fmt.Sprintf("%s/%s.html", JD.Item, vv.Sid),
+					Count: vv.Num,
+				})
+			}
+			continue
+		}
+		p := &define.Promotion{
+			Type: define.PromTag,
+			Code: v.Code,
+			Pid:  v.Pid,
+			Text: fmt.Sprintf("【%s】%s", v.Name, v.Content),
+			URL:  v.AdURL,
+		}
+		switch v.Code {
+		case "15": // 满减
+			var a, b float64
+			if strings.Contains(v.Content, "选") {
+				fmt.Sscanf(v.Content, "%f元选%f件", &a, &b)
+				p.Type = define.PromPickN
+				p.Threshold, p.Count = a, int64(b)
+				p.Rate = a / b / price
+			} else {
+				s := v.Content
+				if n := strings.LastIndex(s, "最多"); n != -1 {
+					s = s[:n]
+				}
+				if n := strings.LastIndex(s, "满"); n != -1 {
+					s = s[n:]
+				}
+				fmt.Sscanf(formatStr(s), "%f元%f元", &a, &b)
+				p.Type = define.PromFullReduction
+				p.Threshold, p.Discount = a, b
+				p.Each = strings.Contains(v.Content, "每满")
+				if n := strings.LastIndex(v.Content, "最多"); n != -1 {
+					fmt.Sscanf(formatStr(v.Content[n:]), "%f", &p.Cap)
+				}
+				p.Rate = (a - b) / a
+			}
+		case "19": // 多买优惠
+			var dis float64
+			if n := strings.LastIndex(v.Content, "打"); n != -1 {
+				fmt.Sscanf(v.Content[n:], "打%f折", &dis)
+			}
+			if n := strings.LastIndex(v.Content, "满"); n != -1 {
+				fmt.Sscanf(v.Content[n:], "满%d件", &p.Count)
+			}
+			p.Type = define.PromMultiBuy
+			p.Rate = dis / 10
+		}
+		if math.IsNaN(p.Rate) || math.IsInf(p.Rate, 0) {
+			p.Rate = 0
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// parseTiers 解析阶梯券档位，兼容数值及字符串
+func parseTiers(in json.RawMessage) []*define.Tier {
+	var v interface{}
+	if len(in) == 0 || json.Unmarshal(in, &v) != nil {
+		return nil
+	}
+	var out []*define.Tier
+	var walk func(interface{})
+	walk = func(v interface{}) {
+		switch vv := v.(type) {
+		case []interface{}:
+			for _, e := range vv {
+				walk(e)
+			}
+		case map[string]interface{}:
+			quota, ok1 := toFloat(vv["quota"])
+			discount, ok2 := toFloat(vv["discount"])
+			if ok1 && ok2 {
+				out = append(out, &define.Tier{Quota: quota, Discount: discount})
+				return
+			}
+			for _, e := range vv {
+				walk(e)
+			}
+		}
+	}
+	walk(v)
+	sort.Slice(out, func(i, j int) bool { return out[i].Quota < out[j].Quota })
+	return out
+}
+
+func toFloat(in interface{}) (float64, bool) {
+	switch v := in.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func formatStr(in string) (out string) {
+	for _, v := range in {
+		if unicode.IsNumber(v) || v == '.' || v == '元' {
+			out += string(v)
+		}
+	}
+	return
+}
+
+// validatePage 校验页面配置各字段
+func validatePage(page *define.Page) {
+	jdpc := page.Config.(*define.JDPageConfig)
+	missing := make(map[string]bool)
+	for _, v := range page.Missing {
+		missing[v] = true
+	}
+	check := func(field string, ok bool) {
+		health.Check("jd.pageConfig."+field, page.SkuID, !missing[field] && ok, page.Raw)
+	}
+	check("skuid", jdpc.SkuID == page.SkuID)
+	check("name", jdpc.Name != "")
+	check("src", !strings.HasSuffix(jdpc.Src, "/"))
+	check("cat", len(jdpc.Cat) != 0)
+	check("koBeginTime", jdpc.KoBeginTime >= 0)
+	check("koEndTime", jdpc.KoEndTime == 0 || jdpc.KoEndTime >= jdpc.KoBeginTime)
+}
+
+// validatePromotions 按类型校验解析出的促销数值
+func validatePromotions(sku int64, proms []*define.Promotion, idt []byte) {
+	for _, v := range proms {
+		var ok bool
+		switch v.Type {
+		case define.PromCoupon:
+			ok = v.Discount > 0 && v.Threshold >= 0
+		case define.PromStepCoupon:
+			ok = len(v.Tiers) != 0
+		case define.PromFullReduction:
+			ok = v.Threshold > 0 && v.Discount > 0 && v.Discount < v.Threshold
+		case define.PromPickN:
+			ok = v.Threshold > 0 && v.Count > 0
+		case define.PromMultiBuy:
+			ok = v.Count > 0 && v.Rate > 0 && v.Rate < 1
+		case define.PromGift:
+			ok = v.Count > 0
+		case define.PromUnknown:
+		default:
+			continue
+		}
+		health.Check("jd.promotion."+v.Type, sku, ok, idt)
+	}
+}
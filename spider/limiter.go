@@ -0,0 +1,104 @@
+package spider
+
+import (
+	"context"
+	"math/rand"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/panshiqu/shopping/define"
+)
+
+// Limit 令牌桶限速
+type Limit struct {
+	Rate  float64 // 每秒请求数
+	Burst float64 // 突发请求数
+}
+
+var (
+	// DefaultLimit 每个域名的默认限速
+	DefaultLimit = Limit{Rate: 1, Burst: 2}
+
+	// HostLimits 按域名覆盖默认限速
+	HostLimits = map[string]Limit{}
+
+	// Jitter 每次请求额外随机等待的最长时间
+	Jitter = 500 * time.Millisecond
+)
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+
+	requests int64
+	waited   time.Duration
+}
+
+// reserve 取一个令牌，不足时预支并返回需要等待的时间
+func (b *bucket) reserve(now time.Time) time.Duration {
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if b.tokens > b.limit.Burst {
+		b.tokens = b.limit.Burst
+	}
+	b.last = now
+	b.tokens--
+	b.requests++
+	if b.tokens >= 0 || b.limit.Rate <= 0 {
+		return 0
+	}
+	d := time.Duration(-b.tokens / b.limit.Rate * float64(time.Second))
+	b.waited += d
+	return d
+}
+
+var (
+	bucketMtx sync.Mutex
+	buckets   = make(map[string]*bucket)
+)
+
+// wait 按请求域名限速并加入随机抖动
+func wait(ctx context.Context, in string) error {
+	u, err := url.Parse(in)
+	if err != nil {
+		return err
+	}
+
+	bucketMtx.Lock()
+	b, ok := buckets[u.Host]
+	if !ok {
+		limit, ok := HostLimits[u.Host]
+		if !ok {
+			limit = DefaultLimit
+		}
+		b = &bucket{limit: limit, tokens: limit.Burst, last: time.Now()}
+		buckets[u.Host] = b
+	}
+	d := b.reserve(time.Now())
+	bucketMtx.Unlock()
+
+	if Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(Jitter)))
+	}
+	return sleep(ctx, d)
+}
+
+func hostStats() []*define.HostStats {
+	bucketMtx.Lock()
+	defer bucketMtx.Unlock()
+
+	out := []*define.HostStats{}
+	for k, v := range buckets {
+		out = append(out, &define.HostStats{
+			Host:     k,
+			Rate:     v.limit.Rate,
+			Burst:    v.limit.Burst,
+			Requests: v.requests,
+			Waited:   v.waited.Milliseconds(),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out
+}
@@ -0,0 +1,331 @@
+package spider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/panshiqu/shopping/define"
+)
+
+// ErrSyntax 页面配置无法解析
+var ErrSyntax = errors.New("pageConfig syntax")
+
+// requiredFields 缺失时改用 otto 解析的字段
+var requiredFields = map[string]bool{"skuid": true, "name": true}
+
+// parsePageConfig 解析 var pageConfig = {...}; 对象字面量中的商品字段，
+// 无法识别的表达式跳过，返回缺失或类型不符的字段
+func parsePageConfig(in []byte) (*define.JDPageConfig, []string, error) {
+	begin := bytes.IndexByte(in, '=')
+	if begin == -1 {
+		return nil, nil, ErrSyntax
+	}
+
+	p := &jsParser{in: in, pos: begin + 1}
+	v, err := p.value()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root, _ := v.(map[string]interface{})
+	product, _ := root["product"].(map[string]interface{})
+
+	var missing []string
+	jdpc := &define.JDPageConfig{}
+	var ok bool
+
+	if jdpc.SkuID, ok = jsInt(product["skuid"]); !ok {
+		missing = append(missing, "skuid")
+	}
+	if jdpc.Name, ok = product["name"].(string); !ok {
+		missing = append(missing, "name")
+	}
+	if jdpc.KoBeginTime, ok = jsInt(product["koBeginTime"]); !ok {
+		missing = append(missing, "koBeginTime")
+	}
+	if jdpc.KoEndTime, ok = jsInt(product["koEndTime"]); !ok {
+		missing = append(missing, "koEndTime")
+	}
+	src, ok := product["src"].(string)
+	if !ok {
+		missing = append(missing, "src")
+	}
+	jdpc.Src = fmt.Sprintf("http://img14.360buyimg.com/n1/%s", src)
+	if cat, ok := product["cat"].([]interface{}); ok {
+		for _, v := range cat {
+			if n, ok := jsInt(v); ok {
+				jdpc.Cat = append(jdpc.Cat, n)
+			}
+		}
+	}
+	if len(jdpc.Cat) == 0 {
+		missing = append(missing, "cat")
+	}
+
+	return jdpc, missing, nil
+}
+
+func jsInt(in interface{}) (int64, bool) {
+	switch v := in.(type) {
+	case float64:
+		return int64(v), true
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// jsParser 只识别对象、数组、字符串、数字及字面量，其余表达式按 nil 跳过
+type jsParser struct {
+	in  []byte
+	pos int
+}
+
+func (p *jsParser) peek() byte {
+	if p.pos < len(p.in) {
+		return p.in[p.pos]
+	}
+	return 0
+}
+
+// space 跳过空白及注释
+func (p *jsParser) space() {
+	for p.pos < len(p.in) {
+		switch c := p.in[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			p.pos++
+		case c == '/' && p.pos+1 < len(p.in) && p.in[p.pos+1] == '/':
+			if n := bytes.IndexByte(p.in[p.pos:], '\n'); n != -1 {
+				p.pos += n + 1
+			} else {
+				p.pos = len(p.in)
+			}
+		case c == '/' && p.pos+1 < len(p.in) && p.in[p.pos+1] == '*':
+			if n := bytes.Index(p.in[p.pos+2:], []byte("*/")); n != -1 {
+				p.pos += n + 4
+			} else {
+				p.pos = len(p.in)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *jsParser) value() (interface{}, error) {
+	p.space()
+
+	var v interface{}
+	var err error
+
+	switch c := p.peek(); {
+	case c == '{':
+		v, err = p.object()
+	case c == '[':
+		v, err = p.array()
+	case c == '\'' || c == '"':
+		v, err = p.str()
+	case c == '-' || c == '.' || c >= '0' && c <= '9':
+		v = p.number()
+	case c == 0:
+		return nil, ErrSyntax
+	default:
+		switch p.ident() {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// 值后跟运算、调用等表达式时整体跳过
+	p.space()
+	switch p.peek() {
+	case ',', '}', ']', ';', 0:
+		return v, nil
+	}
+	return nil, p.skip()
+}
+
+func (p *jsParser) object() (map[string]interface{}, error) {
+	out := make(map[string]interface{})
+	p.pos++
+	for {
+		p.space()
+		if p.peek() == '}' {
+			p.pos++
+			return out, nil
+		}
+
+		var key string
+		switch c := p.peek(); {
+		case c == '\'' || c == '"':
+			s, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		case c == 0:
+			return nil, ErrSyntax
+		default:
+			key = p.ident()
+		}
+
+		p.space()
+		if p.peek() == ':' {
+			p.pos++
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+		} else if err := p.skip(); err != nil {
+			return nil, err
+		}
+
+		p.space()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+		default:
+			return nil, ErrSyntax
+		}
+	}
+}
+
+func (p *jsParser) array() ([]interface{}, error) {
+	var out []interface{}
+	p.pos++
+	for {
+		p.space()
+		if p.peek() == ']' {
+			p.pos++
+			return out, nil
+		}
+
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+
+		p.space()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, ErrSyntax
+		}
+	}
+}
+
+func (p *jsParser) str() (string, error) {
+	quote := p.in[p.pos]
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.in) {
+		c := p.in[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return sb.String(), nil
+		case c == '\\' && p.pos < len(p.in):
+			e := p.in[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case 'u', 'x':
+				size := 4
+				if e == 'x' {
+					size = 2
+				}
+				if p.pos+size <= len(p.in) {
+					if r, err := strconv.ParseUint(string(p.in[p.pos:p.pos+size]), 16, 32); err == nil {
+						sb.WriteRune(rune(r))
+						p.pos += size
+						break
+					}
+				}
+				sb.WriteByte(e)
+			default:
+				sb.WriteByte(e)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", ErrSyntax
+}
+
+func (p *jsParser) number() interface{} {
+	begin := p.pos
+	for p.pos < len(p.in) {
+		c := p.in[p.pos]
+		if !(c >= '0' && c <= '9' || c == '.' || c == '-' || c == '+' || c == 'e' || c == 'E') {
+			break
+		}
+		p.pos++
+	}
+	if v, err := strconv.ParseFloat(string(p.in[begin:p.pos]), 64); err == nil {
+		return v
+	}
+	return nil
+}
+
+func (p *jsParser) ident() string {
+	begin := p.pos
+	for p.pos < len(p.in) {
+		r, size := utf8.DecodeRune(p.in[p.pos:])
+		if !(r == '_' || r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r >= utf8.RuneSelf) {
+			break
+		}
+		p.pos += size
+	}
+	return string(p.in[begin:p.pos])
+}
+
+// skip 跳过表达式直到同层的 , } ] ;
+func (p *jsParser) skip() error {
+	depth := 0
+	for p.pos < len(p.in) {
+		p.space()
+		switch c := p.peek(); c {
+		case '\'', '"':
+			if _, err := p.str(); err != nil {
+				return err
+			}
+			continue
+		case '{', '[', '(':
+			depth++
+		case '}', ']', ')':
+			if depth == 0 {
+				return nil
+			}
+			depth--
+		case ',', ';':
+			if depth == 0 {
+				return nil
+			}
+		case 0:
+			return nil
+		}
+		p.pos++
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package spider
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/fakejd"
+)
+
+// pageConfigs 模拟商品页的页面配置
+func pageConfigs(tb testing.TB) (out [][]byte) {
+	for _, v := range fakejd.Demo() {
+		body, err := fakejd.Page(v)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		pc, err := getPageConfig(body)
+		if err != nil {
+			tb.Fatal(v.SkuID, err)
+		}
+		if pc, err = gbk2utf8(pc); err != nil {
+			tb.Fatal(v.SkuID, err)
+		}
+		out = append(out, pc)
+	}
+	return
+}
+
+// TestPageConfig 轻量解析器与 otto 的解析结果一致
+func TestPageConfig(t *testing.T) {
+	for _, pc := range pageConfigs(t) {
+		a, _, err := parsePageConfig(pc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _, err := js2Go(pc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("pageConfig mismatch: %+v %+v", a, b)
+		}
+	}
+}
+
+// BenchmarkPageConfig 对比轻量解析器与 otto 解析页面配置的耗时及内存分配
+func BenchmarkPageConfig(b *testing.B) {
+	pcs := pageConfigs(b)
+
+	for _, v := range []struct {
+		name  string
+		parse func([]byte) (*define.JDPageConfig, []string, error)
+	}{
+		{"parser", parsePageConfig},
+		{"otto", js2Go},
+	} {
+		b.Run(v.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				v.parse(pcs[i%len(pcs)])
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package spider
+
+import (
+	"context"
+	"math"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/panshiqu/shopping/fakejd"
+	"github.com/panshiqu/shopping/health"
+	"github.com/panshiqu/shopping/pricing"
+)
+
+// TestPipeline 对模拟京东服务器跑完整抓取解析流程并校验到手价
+func TestPipeline(t *testing.T) {
+	fake := fakejd.New(fakejd.Demo()...)
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	jd, limit, jitter, mode := *JD, DefaultLimit, Jitter, pricing.Default
+	defer func() { *JD, DefaultLimit, Jitter, pricing.Default = jd, limit, jitter, mode }()
+
+	*JD = JDEndpoints{Item: ts.URL, Price: ts.URL, Promotion: ts.URL, Tax: ts.URL}
+	DefaultLimit, Jitter, pricing.Default = Limit{}, 0, pricing.ModeStack
+
+	for _, v := range fake.Items() {
+		_, _, b, err := Probe(context.Background(), "jd", v.SkuID)
+		if err != nil {
+			t.Errorf("%d: %v", v.SkuID, err)
+			continue
+		}
+		if math.Abs(b.Price-v.Want) > 0.005 {
+			t.Errorf("%d: %v, want %v %v", v.SkuID, b.Price, v.Want, b)
+		}
+	}
+
+	for _, v := range health.Stats() {
+		if v.Failed != 0 {
+			t.Errorf("%s: %s %d", v.Field, v.LastError, v.SkuID)
+		}
+	}
+}
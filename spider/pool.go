@@ -0,0 +1,140 @@
+package spider
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/panshiqu/shopping/define"
+)
+
+var (
+	// Workers 同时抓取的商品数
+	Workers = 4
+
+	// QueueSize 待抓取队列长度，队列满时丢弃本次定时抓取
+	QueueSize = 4096
+)
+
+type task struct {
+	r   Retailer
+	sku int64
+}
+
+var (
+	root  = context.Background() // Start 传入的 ctx
+	queue chan *task
+	wg    sync.WaitGroup
+
+	pendingMtx sync.Mutex
+	pending    = make(map[int64]bool) // 已入队或抓取中的商品
+
+	inFlight, enqueued, skipped, dropped, processed, failed int64
+)
+
+func startWorkers(ctx context.Context) {
+	root = ctx
+	queue = make(chan *task, QueueSize)
+	for i := 0; i < Workers; i++ {
+		wg.Add(1)
+		go work(ctx)
+	}
+}
+
+// Wait 等待 Start 传入的 ctx 取消后抓取协程退出
+func Wait() {
+	wg.Wait()
+}
+
+func work(ctx context.Context) {
+	defer wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-queue:
+			atomic.AddInt64(&inFlight, 1)
+			err := allow(t.sku)
+			if err == nil {
+				err = crawl(ctx, t.r, t.sku)
+				if ctx.Err() == nil {
+					report(t.sku, err)
+				}
+			}
+			if err != nil {
+				atomic.AddInt64(&failed, 1)
+				log.Println("work", t.sku, err)
+			}
+			atomic.AddInt64(&inFlight, -1)
+			atomic.AddInt64(&processed, 1)
+
+			unpend(t.sku)
+		}
+	}
+}
+
+// unpend 商品抓取结束或未能入队
+func unpend(sku int64) {
+	pendingMtx.Lock()
+	delete(pending, sku)
+	pendingMtx.Unlock()
+}
+
+// enqueue 加入待抓取队列，已在队列或抓取中时跳过，block 为假且队列满时丢弃
+func enqueue(ctx context.Context, r Retailer, sku int64, block bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	pendingMtx.Lock()
+	if pending[sku] {
+		pendingMtx.Unlock()
+		atomic.AddInt64(&skipped, 1)
+		return nil
+	}
+	pending[sku] = true
+	pendingMtx.Unlock()
+
+	t := &task{r: r, sku: sku}
+	if block {
+		select {
+		case queue <- t:
+			atomic.AddInt64(&enqueued, 1)
+			return nil
+		case <-ctx.Done():
+			unpend(sku)
+			return ctx.Err()
+		}
+	}
+
+	select {
+	case queue <- t:
+		atomic.AddInt64(&enqueued, 1)
+	default:
+		atomic.AddInt64(&dropped, 1)
+		log.Println("enqueue dropped", sku)
+
+		unpend(sku)
+	}
+	return nil
+}
+
+// Stats 抓取队列及各域名限速统计
+func Stats() *define.SpiderStats {
+	open, tripped := breakerStats()
+	return &define.SpiderStats{
+		Workers:    Workers,
+		QueueDepth: len(queue),
+		QueueSize:  cap(queue),
+		InFlight:   atomic.LoadInt64(&inFlight),
+		Enqueued:   atomic.LoadInt64(&enqueued),
+		Skipped:    atomic.LoadInt64(&skipped),
+		Dropped:    atomic.LoadInt64(&dropped),
+		Processed:  atomic.LoadInt64(&processed),
+		Failed:     atomic.LoadInt64(&failed),
+		Open:       open,
+		Tripped:    tripped,
+		Hosts:      hostStats(),
+	}
+}
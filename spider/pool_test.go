@@ -0,0 +1,35 @@
+package spider
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEnqueuePending(t *testing.T) {
+	q := queue
+	defer func() { queue = q }()
+
+	for _, v := range []struct {
+		name  string
+		block bool
+		err   error
+	}{
+		{"阻塞入队超时", true, context.DeadlineExceeded},
+		{"队列满丢弃", false, nil},
+	} {
+		queue = make(chan *task) // 无抓取协程，入队不会成功
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err := enqueue(ctx, nil, -1, v.block)
+		cancel()
+		if err != v.err {
+			t.Errorf("%s: %v, want %v", v.name, err, v.err)
+		}
+		pendingMtx.Lock()
+		ok := pending[-1]
+		pendingMtx.Unlock()
+		if ok {
+			t.Errorf("%s: still pending", v.name)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package spider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/promotion"
+	"github.com/panshiqu/shopping/store"
+)
+
+// ReprocessBatch 每批重放的采样数
+var ReprocessBatch = 500
+
+// Reprocess 用当前解析逻辑重放采样保存的原始数据，重算价格、促销及最低最高价；
+// sku 为零时处理全部商品，dryRun 时只输出差异不写库。运行中的服务需重启以刷新缓存
+func Reprocess(w io.Writer, sku int64, dryRun bool) error {
+	var skus []*define.SKU
+	if sku != 0 {
+		v, err := store.Ins.SKU(sku)
+		if err != nil {
+			return err
+		}
+		skus = append(skus, v)
+	} else {
+		var err error
+		if skus, err = store.Ins.SKUs(); err != nil {
+			return err
+		}
+		sort.Slice(skus, func(i, j int) bool { return skus[i].SkuID < skus[j].SkuID })
+	}
+
+	if len(skus) == 0 {
+		return define.ErrNotExist
+	}
+
+	for _, v := range skus {
+		r, err := Lookup(v.Retailer)
+		if err != nil {
+			return err
+		}
+
+		rp, ok := r.(Replayer)
+		if !ok {
+			fmt.Fprintf(w, "%d %s 不支持重放\n", v.SkuID, r.Name())
+			continue
+		}
+
+		if err := reprocess(w, rp, v, dryRun); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func reprocess(w io.Writer, rp Replayer, in *define.SKU, dryRun bool) error {
+	sku, oldMin, oldMax := in.SkuID, in.MinPrice, in.MaxPrice
+
+	var total, changed, failed, skipped int
+	var last, min, max float64
+
+	for after := int64(0); ; {
+		samples, err := store.Ins.Records(sku, after, ReprocessBatch)
+		if err != nil {
+			return err
+		}
+
+		if len(samples) == 0 {
+			break
+		}
+
+		for _, s := range samples {
+			after = s.ID
+			total++
+
+			price, bdt, proms, err := replay(rp, sku, s)
+			if err == errNoBreakdown {
+				skipped++
+				price = s.Price
+			} else if err != nil {
+				failed++
+				price = s.Price
+				fmt.Fprintf(w, "%d #%d %v\n", sku, s.ID, err)
+			} else {
+				prev := s.Promotions
+
+				if price != s.Price || !bytes.Equal(bdt, s.Breakdown) || !promotion.Equal(prev, proms) {
+					changed++
+					fmt.Fprintf(w, "%d #%d 价格 %v -> %v\n", sku, s.ID, s.Price, price)
+
+					added, removed := promotion.Diff(prev, proms)
+					for _, v := range added {
+						fmt.Fprintf(w, "\t+ %s\n", v.Text)
+					}
+					for _, v := range removed {
+						fmt.Fprintf(w, "\t- %s\n", v.Text)
+					}
+
+					if !dryRun {
+						if err := store.Ins.UpdateRecord(&define.Record{ID: s.ID, SkuID: sku, Price: price, Stock: define.StockOf(price), Breakdown: bdt, Promotions: proms}); err != nil {
+							return err
+						}
+					}
+				}
+			}
+
+			last = price
+			if define.StockOf(price) == define.StockInStock {
+				if price < min || min == 0 {
+					min = price
+				}
+				if price > max || max == 0 {
+					max = price
+				}
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "%d 采样%d次 变化%d次 失败%d次 无价格构成跳过%d次 最低价 %v -> %v 最高价 %v -> %v\n", sku, total, changed, failed, skipped, oldMin, min, oldMax, max)
+
+	if dryRun || total == 0 {
+		return nil
+	}
+
+	return store.Ins.UpdateSKU(sku, min, max, define.StockOf(last))
+}
+
+// errNoBreakdown 早期采样没有价格构成，无法得知税费，不重写
+var errNoBreakdown = errors.New("no breakdown")
+
+// replay 原始数据未保存税费，沿用采样时价格构成中的税费
+func replay(rp Replayer, sku int64, s *define.Record) (float64, []byte, []*define.Promotion, error) {
+	if len(s.Breakdown) == 0 {
+		return 0, nil, nil, errNoBreakdown
+	}
+	page, err := rp.ReplayPage(sku, s.PageConfig)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	price, err := rp.ReplayPrice(page, s.JDPrice)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	proms, b, err := rp.ReplayPromotion(page, s.JDPromotion, price)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	prev := &define.Breakdown{}
+	if err := json.Unmarshal(s.Breakdown, prev); err != nil {
+		return 0, nil, nil, err
+	}
+	b.Tax = prev.Tax
+	price = b.Round()
+	bdt, err := json.Marshal(b)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return price, bdt, proms, nil
+}
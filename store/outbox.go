@@ -0,0 +1,69 @@
+package store
+
+import (
+	"github.com/panshiqu/shopping/db"
+	"github.com/panshiqu/shopping/define"
+)
+
+// AddNotifications 写入待投递通知
+func (t *sqlTx) AddNotifications(in []*define.Notification) error {
+	for _, v := range in {
+		if _, err := t.tx.Exec(db.InsertIgnore()+" INTO outbox (user,sku,type,target,message,dedupe,next_attempt,create_time) VALUES (?,?,?,?,?,?,?,?)",
+			v.UserID, v.SkuID, v.Type, v.Target, v.Message, v.Dedupe, v.NextAttempt, v.CreateTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DueNotifications 到期的待投递通知
+func (s *SQL) DueNotifications(now int64, limit int) ([]*define.Notification, error) {
+	rows, err := db.Ins.Query("SELECT id,type,target,message,attempts,next_attempt FROM outbox WHERE status = ? AND next_attempt <= ? ORDER BY id LIMIT ?", define.NotifyPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var out []*define.Notification
+	for rows.Next() {
+		n := &define.Notification{Status: define.NotifyPending}
+
+		if err := rows.Scan(&n.ID, &n.Type, &n.Target, &n.Message, &n.Attempts, &n.NextAttempt); err != nil {
+			return nil, err
+		}
+
+		out = append(out, n)
+	}
+
+	return out, rows.Err()
+}
+
+// UpdateNotification 更新投递状态
+func (s *SQL) UpdateNotification(in *define.Notification) error {
+	_, err := db.Ins.Exec("UPDATE outbox SET status = ?,attempts = ?,last_error = ?,next_attempt = ?,deliver_time = ? WHERE id = ?", in.Status, in.Attempts, in.LastError, in.NextAttempt, in.DeliverTime, in.ID)
+	return err
+}
+
+// NotificationsOf 用户的通知投递记录
+func (s *SQL) NotificationsOf(id string, limit int64) ([]*define.Notification, error) {
+	rows, err := db.Ins.Query("SELECT id,sku,type,message,status,attempts,last_error,next_attempt,create_time,deliver_time FROM outbox WHERE user = ? ORDER BY id DESC LIMIT ?", id, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	out := []*define.Notification{}
+	for rows.Next() {
+		n := &define.Notification{UserID: id}
+
+		if err := rows.Scan(&n.ID, &n.SkuID, &n.Type, &n.Message, &n.Status, &n.Attempts, &n.LastError, &n.NextAttempt, &n.CreateTime, &n.DeliverTime); err != nil {
+			return nil, err
+		}
+
+		out = append(out, n)
+	}
+
+	return out, rows.Err()
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"encoding/json"
+
+	"github.com/panshiqu/shopping/db"
+	"github.com/panshiqu/shopping/define"
+)
+
+// savePromotions 在事务中保存采样的促销，each 为 MySQL 保留字需加引号
+func savePromotions(tx db.Execer, jd, sku int64, in []*define.Promotion) error {
+	for _, v := range in {
+		var tiers []byte
+		if len(v.Tiers) != 0 {
+			var err error
+			if tiers, err = json.Marshal(v.Tiers); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec("INSERT INTO promotion (jd_id,sku,type,code,pid,text,url,threshold,discount,count,rate,begin_time,end_time,`each`,cap,stackable,tiers) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)",
+			jd, sku, v.Type, v.Code, v.Pid, v.Text, v.URL, v.Threshold, v.Discount, v.Count, v.Rate, v.Begin, v.End, v.Each, v.Cap, v.Stackable, string(tiers)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// promotions 查询采样的促销
+func promotions(jd int64) ([]*define.Promotion, error) {
+	rows, err := db.Ins.Query("SELECT type,code,pid,text,url,threshold,discount,count,rate,begin_time,end_time,`each`,cap,stackable,tiers FROM promotion WHERE jd_id = ? ORDER BY id", jd)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var out []*define.Promotion
+	for rows.Next() {
+		p := &define.Promotion{}
+		var tiers []byte
+
+		if err := rows.Scan(&p.Type, &p.Code, &p.Pid, &p.Text, &p.URL, &p.Threshold, &p.Discount, &p.Count, &p.Rate, &p.Begin, &p.End, &p.Each, &p.Cap, &p.Stackable, &tiers); err != nil {
+			return nil, err
+		}
+
+		if len(tiers) != 0 {
+			if err := json.Unmarshal(tiers, &p.Tiers); err != nil {
+				return nil, err
+			}
+		}
+
+		out = append(out, p)
+	}
+
+	return out, rows.Err()
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"time"
+
+	"github.com/panshiqu/shopping/db"
+	"github.com/panshiqu/shopping/define"
+)
+
+// MarkPurchases 记录已提醒的价格
+func (t *sqlTx) MarkPurchases(in []*define.Purchase, price float64) error {
+	for _, v := range in {
+		if _, err := t.tx.Exec("UPDATE purchase SET notified_price = ? WHERE id = ?", price, v.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddPurchase 记录购买
+func (s *SQL) AddPurchase(in *define.Purchase) error {
+	_, err := db.Ins.Exec("INSERT INTO purchase (user,sku,price,quantity,purchase_time,days,create_time) VALUES (?,?,?,?,?,?,?)",
+		in.UserID, in.SkuID, in.Price, in.Quantity, in.PurchaseTime, in.Days, time.Now().Unix())
+	return err
+}
+
+// DeletePurchase 删除购买记录
+func (s *SQL) DeletePurchase(user string, id int64) error {
+	_, err := db.Ins.Exec("DELETE FROM purchase WHERE id = ? AND user = ?", id, user)
+	return err
+}
+
+// PurchasesOf 用户的购买记录
+func (s *SQL) PurchasesOf(user string) ([]*define.Purchase, error) {
+	return s.purchases("SELECT id,user,sku,price,quantity,purchase_time,days,notified_price FROM purchase WHERE user = ? ORDER BY purchase_time DESC", user)
+}
+
+// ProtectedPurchases 价保期内购买价高于 price 的购买记录
+func (s *SQL) ProtectedPurchases(sku int64, price float64, now int64) ([]*define.Purchase, error) {
+	return s.purchases("SELECT id,user,sku,price,quantity,purchase_time,days,notified_price FROM purchase WHERE sku = ? AND price > ? AND purchase_time + days * 86400 >= ?", sku, price, now)
+}
+
+func (s *SQL) purchases(query string, args ...interface{}) ([]*define.Purchase, error) {
+	rows, err := db.Ins.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	out := []*define.Purchase{}
+	for rows.Next() {
+		p := &define.Purchase{}
+
+		if err := rows.Scan(&p.ID, &p.UserID, &p.SkuID, &p.Price, &p.Quantity, &p.PurchaseTime, &p.Days, &p.NotifiedPrice); err != nil {
+			return nil, err
+		}
+
+		out = append(out, p)
+	}
+
+	return out, rows.Err()
+}
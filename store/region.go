@@ -0,0 +1,86 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/panshiqu/shopping/db"
+	"github.com/panshiqu/shopping/define"
+)
+
+// SaveRegionPrice 保存区域采样
+func (t *sqlTx) SaveRegionPrice(in *define.RegionPrice) error {
+	bdt, err := json.Marshal(in.Breakdown)
+	if err != nil {
+		return err
+	}
+
+	pdt, err := json.Marshal(in.Promotions)
+	if err != nil {
+		return err
+	}
+
+	in.UpdateTime = time.Now().Unix()
+
+	_, err = t.tx.Exec("INSERT INTO area_price (sku,area,price,min_price,stock,breakdown,promotions,update_time) VALUES (?,?,?,?,?,?,?,?) "+db.Upsert("sku,area", "price", "min_price", "stock", "breakdown", "promotions", "update_time"),
+		in.SkuID, in.Area, in.Price, in.MinPrice, in.Stock, bdt, pdt, in.UpdateTime)
+	return err
+}
+
+// RegionPrice 商品在该区域的上次采样
+func (s *SQL) RegionPrice(sku int64, area string) (*define.RegionPrice, error) {
+	out := &define.RegionPrice{SkuID: sku, Area: area}
+
+	var bdt, pdt []byte
+
+	if err := db.Ins.QueryRow("SELECT price,min_price,stock,breakdown,promotions,update_time FROM area_price WHERE sku = ? AND area = ?", sku, area).Scan(&out.Price, &out.MinPrice, &out.Stock, &bdt, &pdt, &out.UpdateTime); err != nil {
+		if err == sql.ErrNoRows {
+			return out, nil
+		}
+		return nil, err
+	}
+
+	return out, decodeRegion(out, bdt, pdt)
+}
+
+// RegionPrices 商品各区域的最新价格
+func (s *SQL) RegionPrices(sku int64) ([]*define.RegionPrice, error) {
+	rows, err := db.Ins.Query("SELECT area,price,min_price,stock,breakdown,promotions,update_time FROM area_price WHERE sku = ? ORDER BY area", sku)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var out []*define.RegionPrice
+	for rows.Next() {
+		rp := &define.RegionPrice{SkuID: sku}
+		var bdt, pdt []byte
+
+		if err := rows.Scan(&rp.Area, &rp.Price, &rp.MinPrice, &rp.Stock, &bdt, &pdt, &rp.UpdateTime); err != nil {
+			return nil, err
+		}
+
+		if err := decodeRegion(rp, bdt, pdt); err != nil {
+			return nil, err
+		}
+
+		out = append(out, rp)
+	}
+
+	return out, rows.Err()
+}
+
+func decodeRegion(in *define.RegionPrice, bdt, pdt []byte) error {
+	if len(bdt) != 0 && string(bdt) != "null" {
+		in.Breakdown = &define.Breakdown{}
+		if err := json.Unmarshal(bdt, in.Breakdown); err != nil {
+			return err
+		}
+	}
+	if len(pdt) != 0 {
+		return json.Unmarshal(pdt, &in.Promotions)
+	}
+	return nil
+}
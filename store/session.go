@@ -0,0 +1,36 @@
+package store
+
+import (
+	"github.com/panshiqu/shopping/db"
+	"github.com/panshiqu/shopping/define"
+)
+
+// AddSession 保存会话
+func (s *SQL) AddSession(in *define.Session, now int64) error {
+	if _, err := db.Ins.Exec("DELETE FROM session WHERE expire_timestamp < ?", now); err != nil {
+		return err
+	}
+	_, err := db.Ins.Exec("INSERT INTO session (token,id,csrf,expire_timestamp) VALUES (?,?,?,?)", in.Token, in.ID, in.CSRF, in.Expire)
+	return err
+}
+
+// Session 未过期的会话
+func (s *SQL) Session(token string, now int64) (*define.Session, error) {
+	out := &define.Session{Token: token}
+	if err := db.Ins.QueryRow("SELECT id,csrf,expire_timestamp FROM session WHERE token = ? AND expire_timestamp >= ?", token, now).Scan(&out.ID, &out.CSRF, &out.Expire); err != nil {
+		return nil, notExist(err)
+	}
+	return out, nil
+}
+
+// DeleteSession 删除会话
+func (s *SQL) DeleteSession(token string) error {
+	_, err := db.Ins.Exec("DELETE FROM session WHERE token = ?", token)
+	return err
+}
+
+// DeleteSessions 删除用户的全部会话
+func (s *SQL) DeleteSessions(id string) error {
+	_, err := db.Ins.Exec("DELETE FROM session WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,416 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/panshiqu/shopping/db"
+	"github.com/panshiqu/shopping/define"
+)
+
+// SQL 基于 db.Ins 的存储，支持 MySQL 及 SQLite
+type SQL struct {
+}
+
+// unix 时间戳列转为秒，SQLite 直接保存秒
+func unix(col string) string {
+	if db.Driver == db.SQLite {
+		return col
+	}
+	return "UNIX_TIMESTAMP(" + col + ")"
+}
+
+// fromUnix 秒转为时间戳列的占位符
+func fromUnix() string {
+	if db.Driver == db.SQLite {
+		return "?"
+	}
+	return "FROM_UNIXTIME(?)"
+}
+
+func notExist(err error) error {
+	if err == sql.ErrNoRows {
+		return define.ErrNotExist
+	}
+	return err
+}
+
+// AddSKU 增加商品
+func (s *SQL) AddSKU(in *define.SKU) error {
+	var retailer string
+	if err := db.Ins.QueryRow("SELECT retailer FROM sku WHERE sku = ?", in.SkuID).Scan(&retailer); err != sql.ErrNoRows {
+		if err == nil {
+			err = define.ErrAlreadyExist
+		}
+		return err
+	}
+	_, err := db.Ins.Exec("INSERT INTO sku (sku,priority,retailer) VALUES (?,?,?)", in.SkuID, in.Priority, in.Retailer)
+	return err
+}
+
+// SKU 查询商品
+func (s *SQL) SKU(sku int64) (*define.SKU, error) {
+	out := &define.SKU{SkuID: sku}
+	if err := db.Ins.QueryRow("SELECT priority,min_price,max_price,retailer,stock,"+unix("insert_timestamp")+" FROM sku WHERE sku = ?", sku).Scan(&out.Priority, &out.MinPrice, &out.MaxPrice, &out.Retailer, &out.Stock, &out.InsertTimestamp); err != nil {
+		return nil, notExist(err)
+	}
+	return out, nil
+}
+
+// SKUs 全部商品
+func (s *SQL) SKUs() ([]*define.SKU, error) {
+	rows, err := db.Ins.Query("SELECT sku,priority,min_price,max_price,retailer,stock," + unix("insert_timestamp") + " FROM sku ORDER BY priority")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var out []*define.SKU
+	for rows.Next() {
+		v := &define.SKU{}
+
+		if err := rows.Scan(&v.SkuID, &v.Priority, &v.MinPrice, &v.MaxPrice, &v.Retailer, &v.Stock, &v.InsertTimestamp); err != nil {
+			return nil, err
+		}
+
+		out = append(out, v)
+	}
+
+	return out, rows.Err()
+}
+
+// UpdateSKU 更新最低价、最高价及库存状态
+func (s *SQL) UpdateSKU(sku int64, min, max float64, stock int64) error {
+	_, err := db.Ins.Exec("UPDATE sku SET min_price = ?,max_price = ?,stock = ? WHERE sku = ?", min, max, stock, sku)
+	return err
+}
+
+// AddSample 保存采样及促销
+func (s *SQL) AddSample(ctx context.Context, in *define.Record, then func(Tx) error) (int64, error) {
+	tx, err := db.Ins.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec("INSERT INTO jd (sku,price,stock,breakdown,jd_price,jd_promotion,jd_page_config,ko_begin_time,ko_end_time) VALUES (?,?,?,?,?,?,?,?,?)",
+		in.SkuID, in.Price, in.Stock, in.Breakdown, in.JDPrice, in.JDPromotion, in.PageConfig, in.KoBeginTime, in.KoEndTime)
+	if err != nil {
+		return 0, err
+	}
+	jd, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := savePromotions(tx, jd, in.SkuID, in.Promotions); err != nil {
+		return 0, err
+	}
+	if then != nil {
+		if err := then(&sqlTx{tx}); err != nil {
+			return 0, err
+		}
+	}
+	return jd, tx.Commit()
+}
+
+// LastSample 最近一次采样
+func (s *SQL) LastSample(sku int64) (*define.Record, error) {
+	out := &define.Record{SkuID: sku}
+	if err := db.Ins.QueryRow("SELECT id,price,stock,breakdown,ko_begin_time,ko_end_time,"+unix("record_timestamp")+" FROM jd WHERE sku = ? ORDER BY id DESC LIMIT 1", sku).Scan(&out.ID, &out.Price, &out.Stock, &out.Breakdown, &out.KoBeginTime, &out.KoEndTime, &out.Timestamp); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var err error
+	if out.Promotions, err = promotions(out.ID); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CountSamples 采样次数
+func (s *SQL) CountSamples(sku int64) (n int64, err error) {
+	err = db.Ins.QueryRow("SELECT COUNT(*) FROM jd WHERE sku = ?", sku).Scan(&n)
+	return
+}
+
+// Samples 时间范围内的采样
+func (s *SQL) Samples(sku, begin, end int64) ([]*define.Record, error) {
+	rows, err := db.Ins.Query("SELECT id,price,stock,breakdown,ko_begin_time,ko_end_time,"+unix("record_timestamp")+" FROM jd WHERE sku = ? AND record_timestamp >= "+fromUnix()+" AND record_timestamp < "+fromUnix()+" ORDER BY id", sku, begin, end)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var out []*define.Record
+	for rows.Next() {
+		v := &define.Record{SkuID: sku}
+
+		if err := rows.Scan(&v.ID, &v.Price, &v.Stock, &v.Breakdown, &v.KoBeginTime, &v.KoEndTime, &v.Timestamp); err != nil {
+			return nil, err
+		}
+
+		out = append(out, v)
+	}
+
+	return out, rows.Err()
+}
+
+// Average 均价
+func (s *SQL) Average(sku, since int64) (float64, error) {
+	var avg sql.NullFloat64
+	if err := db.Ins.QueryRow("SELECT AVG(price) FROM jd WHERE sku = ? AND price >= 0 AND record_timestamp >= "+fromUnix(), sku, since).Scan(&avg); err != nil {
+		return 0, err
+	}
+	return avg.Float64, nil
+}
+
+// Records 用于重放的采样
+func (s *SQL) Records(sku, after int64, limit int) ([]*define.Record, error) {
+	rows, err := db.Ins.Query("SELECT id,price,stock,breakdown,jd_price,jd_promotion,jd_page_config,ko_begin_time,ko_end_time,"+unix("record_timestamp")+" FROM jd WHERE sku = ? AND id > ? ORDER BY id LIMIT ?", sku, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var out []*define.Record
+	for rows.Next() {
+		v := &define.Record{SkuID: sku}
+
+		if err := rows.Scan(&v.ID, &v.Price, &v.Stock, &v.Breakdown, &v.JDPrice, &v.JDPromotion, &v.PageConfig, &v.KoBeginTime, &v.KoEndTime, &v.Timestamp); err != nil {
+			return nil, err
+		}
+
+		out = append(out, v)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, v := range out {
+		if v.Promotions, err = promotions(v.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// UpdateRecord 重写采样
+func (s *SQL) UpdateRecord(in *define.Record) error {
+	tx, err := db.Ins.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// MySQL 的 record_timestamp 更新时默认取当前时间，需显式保持
+	if _, err := tx.Exec("UPDATE jd SET price = ?,stock = ?,breakdown = ?,record_timestamp = record_timestamp WHERE id = ?", in.Price, in.Stock, in.Breakdown, in.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM promotion WHERE jd_id = ?", in.ID); err != nil {
+		return err
+	}
+	if err := savePromotions(tx, in.ID, in.SkuID, in.Promotions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Bind 绑定
+func (s *SQL) Bind(in *define.User) error {
+	var id string
+	if err := db.Ins.QueryRow("SELECT id FROM user WHERE alias = ? AND id <> ?", in.Alias, in.ID).Scan(&id); err != sql.ErrNoRows {
+		if err == nil {
+			err = define.ErrAlreadyExist
+		}
+		return err
+	}
+	_, err := db.Ins.Exec("INSERT INTO user (id,alias,password,area) VALUES (?,?,?,?) "+db.Upsert("id", "alias", "password", "area"), in.ID, in.Alias, in.Password, in.Area)
+	return err
+}
+
+func (s *SQL) user(query string, args ...interface{}) (*define.User, error) {
+	out := &define.User{}
+	if err := db.Ins.QueryRow("SELECT id,alias,password,area,admin,share_token FROM user WHERE "+query, args...).Scan(&out.ID, &out.Alias, &out.Password, &out.Area, &out.Admin, &out.Share); err != nil {
+		return nil, notExist(err)
+	}
+	return out, nil
+}
+
+// User 按编号查询
+func (s *SQL) User(id string) (*define.User, error) {
+	return s.user("id = ?", id)
+}
+
+// UserByAlias 按别名查询
+func (s *SQL) UserByAlias(alias string) (*define.User, error) {
+	return s.user("alias = ?", alias)
+}
+
+// SetPassword 改存密码摘要
+func (s *SQL) SetPassword(id, hash string) error {
+	_, err := db.Ins.Exec("UPDATE user SET password = ? WHERE id = ?", hash, id)
+	return err
+}
+
+// UserByShare 按分享令牌摘要查询
+func (s *SQL) UserByShare(digest string) (*define.User, error) {
+	if digest == "" {
+		return nil, define.ErrNotExist
+	}
+	return s.user("share_token = ?", digest)
+}
+
+// SetShare 设置分享令牌摘要
+func (s *SQL) SetShare(id, digest string) error {
+	_, err := db.Ins.Exec("UPDATE user SET share_token = ? WHERE id = ?", digest, id)
+	return err
+}
+
+// Users 全部用户
+func (s *SQL) Users() ([]*define.User, error) {
+	rows, err := db.Ins.Query("SELECT id,alias,password,area,admin,share_token FROM user ORDER BY alias")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var out []*define.User
+	for rows.Next() {
+		v := &define.User{}
+
+		if err := rows.Scan(&v.ID, &v.Alias, &v.Password, &v.Area, &v.Admin, &v.Share); err != nil {
+			return nil, err
+		}
+
+		out = append(out, v)
+	}
+
+	return out, rows.Err()
+}
+
+// Admins 管理员
+func (s *SQL) Admins() ([]string, error) {
+	rows, err := db.Ins.Query("SELECT id FROM user WHERE admin = 1")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var id string
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		out = append(out, id)
+	}
+
+	return out, rows.Err()
+}
+
+// Subscribe 订阅
+func (s *SQL) Subscribe(id string, in *define.Subscription) error {
+	_, err := db.Ins.Exec("INSERT INTO subscribe (id,sku,keywords,rule,target,days,base_price,prom_alert,area) VALUES (?,?,?,?,?,?,?,?,?) "+db.Upsert("id,sku", "keywords", "rule", "target", "days", "base_price", "prom_alert", "area"),
+		id, in.SkuID, in.Keywords, in.Rule, in.Target, in.Days, in.BasePrice, in.PromAlert, in.Area)
+	return err
+}
+
+// Unsubscribe 退订
+func (s *SQL) Unsubscribe(id string, sku int64) error {
+	_, err := db.Ins.Exec("DELETE FROM subscribe WHERE id = ? AND sku = ?", id, sku)
+	return err
+}
+
+// SubscriptionsOf 用户的订阅
+func (s *SQL) SubscriptionsOf(id string) ([]*define.Subscription, error) {
+	return s.subscriptions("SELECT id,sku,keywords,rule,target,days,base_price,prom_alert,area FROM subscribe WHERE id = ? ORDER BY keywords", id)
+}
+
+// Subscribers 商品的订阅
+func (s *SQL) Subscribers(sku int64) ([]*define.Subscription, error) {
+	return s.subscriptions("SELECT s.id,s.sku,s.keywords,s.rule,s.target,s.days,s.base_price,s.prom_alert,CASE WHEN s.area <> '' THEN s.area ELSE IFNULL(u.area,'') END FROM subscribe s LEFT JOIN user u ON u.id = s.id WHERE s.sku = ?", sku)
+}
+
+func (s *SQL) subscriptions(query string, args ...interface{}) ([]*define.Subscription, error) {
+	rows, err := db.Ins.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var out []*define.Subscription
+	for rows.Next() {
+		v := &define.Subscription{}
+
+		if err := rows.Scan(&v.ID, &v.SkuID, &v.Keywords, &v.Rule, &v.Target, &v.Days, &v.BasePrice, &v.PromAlert, &v.Area); err != nil {
+			return nil, err
+		}
+
+		out = append(out, v)
+	}
+
+	return out, rows.Err()
+}
+
+// SetChannel 设置通知渠道
+func (s *SQL) SetChannel(id, typ, target string) error {
+	_, err := db.Ins.Exec("INSERT INTO channel (id,type,target) VALUES (?,?,?) "+db.Upsert("id,type", "target"), id, typ, target)
+	return err
+}
+
+// DeleteChannel 删除通知渠道
+func (s *SQL) DeleteChannel(id, typ string) error {
+	_, err := db.Ins.Exec("DELETE FROM channel WHERE id = ? AND type = ?", id, typ)
+	return err
+}
+
+// ChannelsOf 用户设置的通知渠道
+func (s *SQL) ChannelsOf(id string) ([]*define.Channel, error) {
+	rows, err := db.Ins.Query("SELECT type,target FROM channel WHERE id = ? ORDER BY type", id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var out []*define.Channel
+	for rows.Next() {
+		c := &define.Channel{}
+
+		if err := rows.Scan(&c.Type, &c.Target); err != nil {
+			return nil, err
+		}
+
+		out = append(out, c)
+	}
+
+	return out, rows.Err()
+}
+
+// sqlTx 事务内的写操作
+type sqlTx struct {
+	tx *sql.Tx
+}
+
+// Atomic 在同一事务中执行
+func (s *SQL) Atomic(ctx context.Context, fn func(Tx) error) error {
+	tx, err := db.Ins.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(&sqlTx{tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,223 @@
+package store
+
+import (
+	"context"
+	"strings"
+
+	"github.com/panshiqu/shopping/db"
+	"github.com/panshiqu/shopping/define"
+)
+
+// SKUs 商品，编号在各零售商间唯一，采样不论零售商均保存在 jd 表
+type SKUs interface {
+	// AddSKU 增加商品，编号已被任一零售商使用时返回 ErrAlreadyExist
+	AddSKU(in *define.SKU) error
+
+	// SKU 查询商品，不存在时返回 ErrNotExist
+	SKU(sku int64) (*define.SKU, error)
+
+	// SKUs 全部商品，按优先级排序
+	SKUs() ([]*define.SKU, error)
+
+	// UpdateSKU 更新最低价、最高价及库存状态
+	UpdateSKU(sku int64, min, max float64, stock int64) error
+}
+
+// Samples 采样
+type Samples interface {
+	// AddSample 保存采样及促销，then 与其在同一事务中执行，返回采样编号
+	AddSample(ctx context.Context, in *define.Record, then func(Tx) error) (int64, error)
+
+	// LastSample 最近一次采样，含促销，不存在时返回 nil
+	LastSample(sku int64) (*define.Record, error)
+
+	// CountSamples 采样次数
+	CountSamples(sku int64) (int64, error)
+
+	// Samples [begin, end) 时间范围内的采样，不含原始数据及促销
+	Samples(sku, begin, end int64) ([]*define.Record, error)
+
+	// Average since 之后的均价，不含下柜采样
+	Average(sku, since int64) (float64, error)
+
+	// Records 编号大于 after 的采样，含原始数据及促销，用于重放
+	Records(sku, after int64, limit int) ([]*define.Record, error)
+
+	// UpdateRecord 重写采样的价格、价格构成及促销，记录时间不变
+	UpdateRecord(in *define.Record) error
+}
+
+// Users 用户
+type Users interface {
+	// Bind 绑定别名、密码及配送区域，Password 为 auth.Hash 的摘要，别名已被他人使用时返回 ErrAlreadyExist
+	Bind(in *define.User) error
+
+	// User 按编号查询，不存在时返回 ErrNotExist
+	User(id string) (*define.User, error)
+
+	// UserByAlias 按别名查询，不存在时返回 ErrNotExist
+	UserByAlias(alias string) (*define.User, error)
+
+	// SetPassword 改存密码摘要
+	SetPassword(id, hash string) error
+
+	// SetShare 设置分享令牌摘要，为空时关闭分享
+	SetShare(id, digest string) error
+
+	// UserByShare 按分享令牌摘要查询，不存在时返回 ErrNotExist
+	UserByShare(digest string) (*define.User, error)
+
+	// Users 全部用户，按别名排序
+	Users() ([]*define.User, error)
+
+	// Admins 管理员编号
+	Admins() ([]string, error)
+}
+
+// Subscriptions 订阅
+type Subscriptions interface {
+	// Subscribe 订阅或更新订阅
+	Subscribe(id string, in *define.Subscription) error
+
+	// Unsubscribe 退订
+	Unsubscribe(id string, sku int64) error
+
+	// SubscriptionsOf 用户的订阅，按关键字排序
+	SubscriptionsOf(id string) ([]*define.Subscription, error)
+
+	// Subscribers 商品的订阅，Area 为订阅的配送区域，未设置时为用户的配送区域
+	Subscribers(sku int64) ([]*define.Subscription, error)
+}
+
+// Channels 通知渠道
+type Channels interface {
+	// SetChannel 设置或更新用户的通知渠道
+	SetChannel(id, typ, target string) error
+
+	// DeleteChannel 删除用户的通知渠道
+	DeleteChannel(id, typ string) error
+
+	// ChannelsOf 用户设置的通知渠道，按渠道排序
+	ChannelsOf(id string) ([]*define.Channel, error)
+}
+
+// Notifications 通知发件箱
+type Notifications interface {
+	// DueNotifications now 时已到期的待投递通知，按编号排序
+	DueNotifications(now int64, limit int) ([]*define.Notification, error)
+
+	// UpdateNotification 更新投递状态、尝试次数、失败原因、下次尝试及投递时间
+	UpdateNotification(in *define.Notification) error
+
+	// NotificationsOf 用户的通知投递记录，按编号倒序
+	NotificationsOf(id string, limit int64) ([]*define.Notification, error)
+}
+
+// Purchases 购买记录
+type Purchases interface {
+	// AddPurchase 记录购买
+	AddPurchase(in *define.Purchase) error
+
+	// DeletePurchase 删除用户的购买记录
+	DeletePurchase(user string, id int64) error
+
+	// PurchasesOf 用户的购买记录，按购买时间倒序
+	PurchasesOf(user string) ([]*define.Purchase, error)
+
+	// ProtectedPurchases now 时仍在价保期内且购买价高于 price 的购买记录
+	ProtectedPurchases(sku int64, price float64, now int64) ([]*define.Purchase, error)
+}
+
+// Regions 分区域价格
+type Regions interface {
+	// RegionPrice 商品在该区域的上次采样，不存在时返回零值
+	RegionPrice(sku int64, area string) (*define.RegionPrice, error)
+
+	// RegionPrices 商品各区域的最新价格，按区域排序
+	RegionPrices(sku int64) ([]*define.RegionPrice, error)
+}
+
+// Sessions 登录会话，Token 为会话令牌的摘要
+type Sessions interface {
+	// AddSession 保存会话并清理 now 时已过期的会话
+	AddSession(in *define.Session, now int64) error
+
+	// Session now 时未过期的会话，不存在时返回 ErrNotExist
+	Session(token string, now int64) (*define.Session, error)
+
+	// DeleteSession 删除会话
+	DeleteSession(token string) error
+
+	// DeleteSessions 删除用户的全部会话
+	DeleteSessions(id string) error
+}
+
+// Tx 与采样等在同一事务中执行的写操作
+type Tx interface {
+	// SaveRegionPrice 保存区域采样
+	SaveRegionPrice(in *define.RegionPrice) error
+
+	// AddNotifications 写入待投递通知，去重键已存在时忽略
+	AddNotifications(in []*define.Notification) error
+
+	// MarkPurchases 记录已提醒的价格
+	MarkPurchases(in []*define.Purchase, price float64) error
+}
+
+// Store 存储
+type Store interface {
+	SKUs
+	Samples
+	Users
+	Subscriptions
+	Channels
+	Notifications
+	Purchases
+	Regions
+	Sessions
+
+	// Atomic 在同一事务中执行 fn，fn 返回错误时回滚
+	Atomic(ctx context.Context, fn func(Tx) error) error
+}
+
+// Ins 实例
+var Ins Store
+
+// Open 按数据源打开存储：mysql://DSN、sqlite://文件路径、memory://，未带前缀时按 MySQL 处理。
+// memory 使用内存 SQLite，进程退出后数据丢失，用于试用及测试。表结构由 migrate 维护
+func Open(dataSource string) error {
+	scheme, dsn := "mysql", dataSource
+	if n := strings.Index(dataSource, "://"); n != -1 {
+		scheme, dsn = dataSource[:n], dataSource[n+3:]
+	}
+
+	switch scheme {
+	case "mysql":
+		if err := db.Open(db.MySQL, dsn); err != nil {
+			return err
+		}
+		Ins = &SQL{}
+	case "sqlite":
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		if err := db.Open(db.SQLite, dsn+sep+"_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"); err != nil {
+			return err
+		}
+		Ins = &SQL{}
+	case "memory":
+		if err := db.Open(db.SQLite, "file:shopping?mode=memory&cache=shared&_pragma=busy_timeout(5000)"); err != nil {
+			return err
+		}
+		// 最后一个连接关闭时内存数据库随之销毁，保留空闲连接且不限存活时长
+		db.Ins.SetMaxIdleConns(4)
+		db.Ins.SetConnMaxLifetime(0)
+		db.Ins.SetConnMaxIdleTime(0)
+		Ins = &SQL{}
+	default:
+		return define.ErrUnknownDataSource
+	}
+
+	return nil
+}
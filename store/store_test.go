@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/panshiqu/shopping/db"
+	"github.com/panshiqu/shopping/define"
+	"github.com/panshiqu/shopping/migrate"
+)
+
+// open 打开测试存储，SHOPPING_TEST_DSN 可指定空的 MySQL 等测试库，默认使用 memory
+func open(t *testing.T) {
+	dsn := os.Getenv("SHOPPING_TEST_DSN")
+	if dsn == "" {
+		dsn = "memory://"
+	}
+	if err := Open(dsn); err != nil {
+		t.Skip("store unavailable:", err)
+	}
+	if _, err := migrate.Up(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	open(t)
+
+	for _, v := range []struct {
+		name string
+		in   *define.SKU
+		err  error
+	}{
+		{"新增", &define.SKU{SkuID: 1, Priority: 2, Retailer: "jd"}, nil},
+		{"重复", &define.SKU{SkuID: 1, Retailer: "jd"}, define.ErrAlreadyExist},
+		{"其他零售商重复", &define.SKU{SkuID: 1, Retailer: "tmall"}, define.ErrAlreadyExist},
+		{"低优先级", &define.SKU{SkuID: 2, Priority: 1, Retailer: "jd"}, nil},
+	} {
+		if err := Ins.AddSKU(v.in); err != v.err {
+			t.Errorf("%s: %v, want %v", v.name, err, v.err)
+		}
+	}
+
+	skus, err := Ins.SKUs()
+	if err != nil || len(skus) != 2 || skus[0].SkuID != 2 {
+		t.Fatalf("SKUs: %v %v", skus, err)
+	}
+
+	if _, err := Ins.AddSample(context.Background(), &define.Record{SkuID: 1, Price: 10}, nil); err != nil {
+		t.Fatal(err)
+	}
+	fail := errors.New("fail")
+	if _, err := Ins.AddSample(context.Background(), &define.Record{SkuID: 1, Price: 20}, func(tx Tx) error {
+		return fail
+	}); err != fail {
+		t.Fatalf("AddSample: %v", err)
+	}
+	if n, err := Ins.CountSamples(1); err != nil || n != 1 {
+		t.Fatalf("CountSamples: %d %v, want 1", n, err)
+	}
+	if r, err := Ins.LastSample(1); err != nil || r == nil || r.Price != 10 {
+		t.Fatalf("LastSample: %v %v", r, err)
+	}
+
+	for _, v := range []struct {
+		name string
+		in   *define.User
+		err  error
+	}{
+		{"绑定", &define.User{ID: "a", Alias: "alice", Password: "secret1"}, nil},
+		{"重新绑定", &define.User{ID: "a", Alias: "alice", Password: "secret2"}, nil},
+		{"别名已被使用", &define.User{ID: "b", Alias: "alice", Password: "secret3"}, define.ErrAlreadyExist},
+	} {
+		if err := Ins.Bind(v.in); err != v.err {
+			t.Errorf("%s: %v, want %v", v.name, err, v.err)
+		}
+	}
+
+	if err := Ins.SetPassword("a", "digest"); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := Ins.UserByAlias("alice"); err != nil || u.ID != "a" || u.Password != "digest" {
+		t.Fatalf("UserByAlias: %v %v", u, err)
+	}
+	if _, err := Ins.UserByAlias("bob"); err != define.ErrNotExist {
+		t.Fatalf("UserByAlias: %v, want %v", err, define.ErrNotExist)
+	}
+
+	if err := Ins.SetShare("a", "share"); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := Ins.UserByShare("share"); err != nil || u.ID != "a" {
+		t.Fatalf("UserByShare: %v %v", u, err)
+	}
+	if err := Ins.SetShare("a", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"share", ""} {
+		if _, err := Ins.UserByShare(v); err != define.ErrNotExist {
+			t.Fatalf("UserByShare(%q): %v, want %v", v, err, define.ErrNotExist)
+		}
+	}
+
+	if err := Ins.AddSession(&define.Session{Token: "t1", ID: "a", Expire: 100}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := Ins.Session("t1", 50); err != nil || s.ID != "a" {
+		t.Fatalf("Session: %v %v", s, err)
+	}
+	if _, err := Ins.Session("t1", 200); err != define.ErrNotExist {
+		t.Fatalf("Session expired: %v, want %v", err, define.ErrNotExist)
+	}
+	if err := Ins.DeleteSessions("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Ins.Session("t1", 50); err != define.ErrNotExist {
+		t.Fatalf("Session deleted: %v, want %v", err, define.ErrNotExist)
+	}
+
+	if _, err := db.Ins.Exec("UPDATE user SET admin = 1 WHERE id = ?", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := Ins.Admins(); err != nil || len(ids) != 1 || ids[0] != "a" {
+		t.Fatalf("Admins: %v %v", ids, err)
+	}
+}
+
+func TestPromotionRoundTrip(t *testing.T) {
+	open(t)
+
+	in := []*define.Promotion{
+		{Type: define.PromFullReduction, Pid: "1", Text: "每满100减10", Threshold: 100, Discount: 10, Each: true, Cap: 50},
+		{Type: define.PromCoupon, Text: "满80减10", Threshold: 80, Discount: 10, Stackable: true},
+	}
+	if _, err := Ins.AddSample(context.Background(), &define.Record{SkuID: 3, Price: 100, Promotions: in}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Ins.LastSample(3)
+	if err != nil || r == nil {
+		t.Fatalf("LastSample: %v %v", r, err)
+	}
+	if !reflect.DeepEqual(r.Promotions, in) {
+		t.Fatalf("promotions %+v, want %+v", r.Promotions, in)
+	}
+}